@@ -7,6 +7,7 @@
 package actions
 
 import (
+	"context"
 	"errors"
 	"github.com/keep94/gohue"
 	"github.com/keep94/maybe"
@@ -14,10 +15,33 @@ import (
 	"time"
 )
 
+var (
+	// ErrRequireOn is the error that Task instances created from Action
+	// instances report when RequireOn is set but the Setter is not also a
+	// Getter or the light IDs are not given explicitly.
+	ErrRequireOn = errors.New(
+		"actions: RequireOn needs a Getter and explicit light ids.")
+
+	// ErrGroupSetter is the error that Task instances created from Action
+	// instances report when an Action targets a non-zero Group but the
+	// Setter is not also a GroupSetter.
+	ErrGroupSetter = errors.New("actions: Group needs a GroupSetter.")
+
+	// ErrCaptureAndRestore is the error that Task instances created from
+	// CaptureAndRestore report when they have no light ids to capture.
+	ErrCaptureAndRestore = errors.New(
+		"actions: CaptureAndRestore needs explicit light ids.")
+)
+
 var (
 	kInvalidLightIdBytes = ([]byte)("Invalid light id")
 )
 
+const (
+	// The transition time the hue bridge uses when none is given.
+	kBridgeDefaultTransitionTime = 4
+)
+
 // NoSuchLightIdError is the error that Task instances created from Action
 // instances report when a light ID is unknown.
 type NoSuchLightIdError struct {
@@ -33,6 +57,21 @@ func (e *NoSuchLightIdError) Error() string {
 	return string(e.RawResponse)
 }
 
+// NoSuchGroupIdError is the error that Task instances created from Action
+// instances report when a group ID is unknown.
+type NoSuchGroupIdError struct {
+
+	// The Unknown group ID
+	GroupId int
+
+	// The raw response received from the hue bridge
+	RawResponse []byte
+}
+
+func (e *NoSuchGroupIdError) Error() string {
+	return string(e.RawResponse)
+}
+
 // ColorDuration specifies the color and/or brightness a light should have a
 // certain duration into a gradient.
 type ColorDuration struct {
@@ -48,12 +87,67 @@ type ColorDuration struct {
 	D time.Duration
 }
 
+// Attributes is a set of light attributes that an Action changes.
+type Attributes uint
+
+const (
+	// AttrC means the Action changes color.
+	AttrC Attributes = 1 << iota
+
+	// AttrBri means the Action changes brightness.
+	AttrBri
+
+	// AttrOn means the Action turns lights on.
+	AttrOn
+
+	// AttrOff means the Action turns lights off.
+	AttrOff
+
+	// AttrAlert means the Action sets an alert.
+	AttrAlert
+
+	// AttrEffect means the Action sets an effect.
+	AttrEffect
+)
+
 // Interface Setter sets the properties of a light. lightId is the ID of the
 // light to set. 0 means all lights.
 type Setter interface {
 	Set(lightId int, properties *gohue.LightProperties) (response []byte, err error)
 }
 
+// Interface GroupSetter sets the properties of all the lights in a group.
+// groupId is the ID of the group. 0 means all lights.
+type GroupSetter interface {
+	SetGroup(groupId int, properties *gohue.LightProperties) (
+		response []byte, err error)
+}
+
+// Interface Getter gets the properties of a light. lightId is the ID of the
+// light to get.
+type Getter interface {
+	Get(lightId int) (
+		properties *gohue.LightProperties, response []byte, err error)
+}
+
+// GradientMode controls what a Gradient does once it reaches its end.
+type GradientMode int
+
+const (
+	// GradientOnce runs the gradient once. This is the default.
+	GradientOnce GradientMode = iota
+
+	// GradientLoop runs the gradient repeatedly. After reaching the last
+	// ColorDuration, the gradient blends back to the first ColorDuration
+	// taking as long as it takes to go from the first to the second
+	// ColorDuration, and then starts over.
+	GradientLoop
+
+	// GradientPingPong runs the gradient forward to the last ColorDuration
+	// and then backward to the first ColorDuration repeatedly.
+	GradientPingPong
+)
+
 // Gradient represents a change in colors and/or brightness over time.
 type Gradient struct {
 
@@ -63,11 +157,17 @@ type Gradient struct {
 
 	// Light color is refreshed this often.
 	Refresh time.Duration
+
+	// What to do once the gradient reaches its end. GradientLoop and
+	// GradientPingPong run until the Task's Execution is ended. They
+	// work like GradientOnce if the last ColorDuration has a D of 0.
+	Mode GradientMode
 }
 
 // Action represents some action to the lights.
 // Callers should set exactly one of the
-// Parallel, Series, G, any subset of {C, Bri, On, Off}, or Sleep fields.
+// Parallel, Series, G, any subset of {C, Bri, On, Off, Alert, Effect},
+// or Sleep fields.
 // The one exception is that On can be used with G. The other
 // fields compliment these fields.
 type Action struct {
@@ -97,9 +197,41 @@ type Action struct {
 	// If true, light(s) are turned off.
 	Off bool
 
+	// The alert such as gohue.AlertSelect to flash light(s) once. Empty
+	// means no alert. May be used alone, for instance as a step in a
+	// Series, without setting color or turning light(s) on or off.
+	Alert gohue.Alert
+
+	// The effect such as gohue.EffectColorLoop. Empty means leave the
+	// effect as is.
+	Effect gohue.Effect
+
+	// If set, the {C, Bri, On, Off, Alert, Effect} fields apply to every
+	// light in this group with a single request. 0 means all lights.
+	// When used with G, each refresh sets the whole group with a single
+	// request instead of setting each light individually, which greatly
+	// cuts the number of requests for gradients over many lights. Group
+	// is opt-in: actions do not detect on their own when all their lights
+	// get the same value. Unless Group is 0, the Setter passed to AsTask
+	// must also implement GroupSetter. When set, the light bulb ids and
+	// RequireOn are ignored.
+	Group maybe.Int
+
+	// If true, only light(s) that are currently on get their color and
+	// brightness changed; light(s) that are off are left alone. Used only
+	// with the {C, Bri} fields and ignored if On is true. The Setter
+	// passed to AsTask must also implement Getter, and the light(s)
+	// must be given explicitly; otherwise the task reports ErrRequireOn.
+	RequireOn bool
+
 	// Transition time in multiples of 100ms. Nothing means default transition
-	// time. See http://developers.meethue.com. Right now it
-	// only works with the {C, Bri, On, Off} fields
+	// time. See http://developers.meethue.com. Works with the
+	// {C, Bri, On, Off} fields and with the G field. Gradients bypass any
+	// default configured on the Setter such as
+	// gohue.Options.DefaultTransitionTime: every request a gradient sends
+	// carries this transition time or, if nothing, 4 (400ms), the hue
+	// bridge's own default. Set this field to opt a gradient into a
+	// different transition time.
 	TransitionTime maybe.Uint16
 
 	// Sleep sleeps this duration
@@ -110,6 +242,48 @@ type Action struct {
 
 	// Actions to be done in parallel
 	Parallel []*Action
+
+	// If non-nil, the action that CaptureAndRestore runs between
+	// snapshotting and restoring lights. Set only by CaptureAndRestore
+	// along with restoreGetter and restoreSetter.
+	restoreBody   *Action
+	restoreGetter Getter
+	restoreSetter Setter
+}
+
+// CaptureAndRestore returns an Action that snapshots the state of lights
+// using getter, runs body, and then restores lights to their snapshotted
+// state. This is useful for notifications that flash lights and then
+// return them to normal. lights must be non-empty as getter cannot get
+// the state of all lights at once; otherwise the task reports
+// ErrCaptureAndRestore without running body. The lights are restored even
+// if body fails or the returned Action is ended early. If getter fails,
+// body is not run.
+func CaptureAndRestore(
+	getter Getter, setter Setter, lights []int, body *Action) *Action {
+	return &Action{
+		Lights:        lights,
+		restoreBody:   body,
+		restoreGetter: getter,
+		restoreSetter: setter,
+	}
+}
+
+// OnWithColor returns an Action that turns on light(s) and then sets them to
+// color c and optionally brightness bri. Setting color on a light that is
+// off fails with gohue.ErrParameterNotModifiable, and sending on and color
+// in the same request sometimes causes the bridge to drop the color. To
+// avoid both problems, the returned Action first turns the light(s) on
+// with no transition and then sets the color and brightness in a separate
+// request. This is the recommended way to apply a color to lights that
+// may be off.
+func OnWithColor(c gohue.Color, bri maybe.Uint8) *Action {
+	return &Action{
+		Series: []*Action{
+			{On: true, TransitionTime: maybe.NewUint16(0)},
+			{C: gohue.NewMaybeColor(c), Bri: bri},
+		},
+	}
 }
 
 // AsTask returns a Task from this instance. setter is what changes the
@@ -124,10 +298,80 @@ func (a *Action) AsTask(setter Setter, lights []int) tasks.Task {
 	return tasks.RepeatingTask(a.asTask(setter, lights), a.Repeat)
 }
 
+// Touches returns the attributes this instance changes when run including
+// the attributes that its child actions change. Touches does not run
+// anything.
+func (a *Action) Touches() Attributes {
+	var result Attributes
+	for _, child := range a.Parallel {
+		result |= child.Touches()
+	}
+	for _, child := range a.Series {
+		result |= child.Touches()
+	}
+	if a.restoreBody != nil {
+		result |= a.restoreBody.Touches()
+	}
+	if a.G != nil {
+		for i := range a.G.Cds {
+			if a.G.Cds[i].C.Valid {
+				result |= AttrC
+			}
+			if a.G.Cds[i].Bri.Valid {
+				result |= AttrBri
+			}
+		}
+	}
+	if a.C.Valid {
+		result |= AttrC
+	}
+	if a.Bri.Valid {
+		result |= AttrBri
+	}
+	if a.On {
+		result |= AttrOn
+	}
+	if a.Off {
+		result |= AttrOff
+	}
+	if a.Alert != "" {
+		result |= AttrAlert
+	}
+	if a.Effect != "" {
+		result |= AttrEffect
+	}
+	return result
+}
+
+// RunAction runs a in a new goroutine and blocks until a finishes.
+// setter and lights work the same way as in AsTask. Cancelling ctx ends
+// a early. a observes cancellation before each Set of a light and while
+// sleeping, so a gradient stops within one refresh interval. A single
+// request that sets a group or all lights is not interrupted.
+// RunAction returns ctx.Err() if a was ended because ctx was cancelled;
+// otherwise it returns the error from running a, if any.
+func RunAction(
+	ctx context.Context, a *Action, setter Setter, lights []int) error {
+	e := tasks.Start(a.AsTask(setter, lights))
+	select {
+	case <-e.Done():
+	case <-ctx.Done():
+		e.End()
+		<-e.Done()
+		return ctx.Err()
+	}
+	return e.Error()
+}
+
 func (a *Action) asTask(setter Setter, lights []int) tasks.Task {
 	if len(a.Lights) > 0 {
 		lights = a.Lights
 	}
+	if a.restoreBody != nil {
+		return tasks.TaskFunc(func(e *tasks.Execution) {
+			a.doCaptureAndRestore(lights, e)
+		})
+	}
 	if len(a.Parallel) > 0 {
 		parallelTasks := make([]tasks.Task, len(a.Parallel))
 		for i := range parallelTasks {
@@ -150,7 +394,8 @@ func (a *Action) asTask(setter Setter, lights []int) tasks.Task {
 			a.doGradient(setter, lights, e)
 		})
 	}
-	if a.C.Valid || a.Bri.Valid || a.On || a.Off {
+	if a.C.Valid || a.Bri.Valid || a.On || a.Off ||
+		a.Alert != "" || a.Effect != "" {
 		return tasks.TaskFunc(func(e *tasks.Execution) {
 			a.doOnOff(setter, lights, e)
 		})
@@ -169,10 +414,73 @@ func (a *Action) doOnOff(setter Setter, lights []int, e *tasks.Execution) {
 	}
 	properties.C = a.C
 	properties.Bri = a.Bri
+	properties.Alert = a.Alert
+	properties.Effect = a.Effect
 	properties.TransitionTime = a.TransitionTime
+	if a.Group.Valid {
+		groupOrMultiSet(e, setter, a.Group, lights, &properties)
+		return
+	}
+	if a.RequireOn && !a.On {
+		var ok bool
+		if lights, ok = onLights(e, setter, lights); !ok {
+			return
+		}
+		if len(lights) == 0 {
+			return
+		}
+	}
 	multiSet(e, setter, lights, &properties)
 }
 
+func (a *Action) doCaptureAndRestore(lights []int, e *tasks.Execution) {
+	if len(lights) == 0 {
+		e.SetError(ErrCaptureAndRestore)
+		return
+	}
+	snapshots := make([]*gohue.LightProperties, len(lights))
+	for i, light := range lights {
+		properties, resp, err := a.restoreGetter.Get(light)
+		if err != nil {
+			e.SetError(fixError(light, resp, err))
+			return
+		}
+		snapshots[i] = properties
+	}
+	a.restoreBody.AsTask(a.restoreSetter, lights).Do(e)
+	for i, light := range lights {
+		if err := restore(a.restoreSetter, light, snapshots[i]); err != nil {
+			if e.Error() == nil {
+				e.SetError(err)
+			}
+			return
+		}
+	}
+}
+
+// onLights returns the lights that are currently on. setter must also be
+// a Getter. If onLights fails, it reports the error to e and returns false.
+func onLights(
+	e *tasks.Execution, setter Setter, lights []int) ([]int, bool) {
+	getter, ok := setter.(Getter)
+	if !ok || len(lights) == 0 {
+		e.SetError(ErrRequireOn)
+		return nil, false
+	}
+	var result []int
+	for _, light := range lights {
+		properties, resp, err := getter.Get(light)
+		if err != nil {
+			e.SetError(fixError(light, resp, err))
+			return nil, false
+		}
+		if properties.On.Valid && properties.On.Value {
+			result = append(result, light)
+		}
+	}
+	return result, true
+}
+
 func (a *Action) doGradient(setter Setter, lights []int, e *tasks.Execution) {
 	startTime := e.Now()
 	var currentD time.Duration
@@ -180,6 +488,14 @@ func (a *Action) doGradient(setter Setter, lights []int, e *tasks.Execution) {
 	if a.On {
 		properties.On.Set(true)
 	}
+	properties.TransitionTime = a.TransitionTime
+	if !properties.TransitionTime.Valid {
+		properties.TransitionTime.Set(kBridgeDefaultTransitionTime)
+	}
+	if a.G.Mode != GradientOnce && a.G.Cds[len(a.G.Cds)-1].D > 0 {
+		a.doCyclicGradient(setter, lights, e, &properties)
+		return
+	}
 	idx := 1
 	last := &a.G.Cds[len(a.G.Cds)-1]
 	for idx < len(a.G.Cds) {
@@ -194,7 +510,7 @@ func (a *Action) doGradient(setter Setter, lights []int, e *tasks.Execution) {
 		aBrightness := maybeBlendBrightness(first.Bri, second.Bri, ratio)
 		properties.C = acolor
 		properties.Bri = aBrightness
-		multiSet(e, setter, lights, &properties)
+		groupOrMultiSet(e, setter, a.Group, lights, &properties)
 		properties.On.Clear()
 		if e.Error() != nil {
 			return
@@ -206,7 +522,99 @@ func (a *Action) doGradient(setter Setter, lights []int, e *tasks.Execution) {
 	}
 	properties.C = last.C
 	properties.Bri = last.Bri
-	multiSet(e, setter, lights, &properties)
+	groupOrMultiSet(e, setter, a.Group, lights, &properties)
+}
+
+// doCyclicGradient runs a gradient in GradientLoop or GradientPingPong
+// mode until e is ended or an error happens.
+func (a *Action) doCyclicGradient(
+	setter Setter,
+	lights []int,
+	e *tasks.Execution,
+	properties *gohue.LightProperties) {
+	startTime := e.Now()
+	var currentD time.Duration
+	for {
+		properties.C, properties.Bri = a.G.cyclicAt(currentD)
+		groupOrMultiSet(e, setter, a.Group, lights, properties)
+		properties.On.Clear()
+		if e.Error() != nil {
+			return
+		}
+		if !e.Sleep(a.G.Refresh) {
+			return
+		}
+		currentD = e.Now().Sub(startTime)
+	}
+}
+
+// cyclicAt returns the color and brightness d into a gradient in
+// GradientLoop or GradientPingPong mode.
+func (g *Gradient) cyclicAt(d time.Duration) (
+	gohue.MaybeColor, maybe.Uint8) {
+	first := &g.Cds[0]
+	last := &g.Cds[len(g.Cds)-1]
+	if g.Mode == GradientPingPong {
+		d %= 2 * last.D
+		if d > last.D {
+			d = 2*last.D - d
+		}
+		return g.at(d)
+	}
+	closing := g.Cds[1].D
+	if closing <= 0 {
+		closing = g.Refresh
+	}
+	d %= last.D + closing
+	if d <= last.D {
+		return g.at(d)
+	}
+	ratio := float64(d-last.D) / float64(closing)
+	return maybeBlendColor(last.C, first.C, ratio),
+		maybeBlendBrightness(last.Bri, first.Bri, ratio)
+}
+
+// at returns the color and brightness d into this gradient.
+func (g *Gradient) at(d time.Duration) (gohue.MaybeColor, maybe.Uint8) {
+	last := &g.Cds[len(g.Cds)-1]
+	if d >= last.D {
+		return last.C, last.Bri
+	}
+	idx := 1
+	for g.Cds[idx].D <= d {
+		idx++
+	}
+	first := &g.Cds[idx-1]
+	second := &g.Cds[idx]
+	ratio := float64(d-first.D) / float64(second.D-first.D)
+	return maybeBlendColor(first.C, second.C, ratio),
+		maybeBlendBrightness(first.Bri, second.Bri, ratio)
+}
+
+// groupOrMultiSet sets group with a single request if group is set;
+// otherwise it sets lights individually.
+func groupOrMultiSet(
+	e *tasks.Execution,
+	setter Setter,
+	group maybe.Int,
+	lights []int,
+	properties *gohue.LightProperties) {
+	if !group.Valid {
+		multiSet(e, setter, lights, properties)
+		return
+	}
+	if group.Value == 0 {
+		multiSet(e, setter, nil, properties)
+		return
+	}
+	groupSetter, ok := setter.(GroupSetter)
+	if !ok {
+		e.SetError(ErrGroupSetter)
+		return
+	}
+	if resp, err := groupSetter.SetGroup(group.Value, properties); err != nil {
+		e.SetError(fixGroupError(group.Value, resp, err))
+	}
 }
 
 func multiSet(
@@ -221,6 +629,9 @@ func multiSet(
 		}
 	} else {
 		for _, light := range lights {
+			if e.IsEnded() {
+				return
+			}
 			if light == 0 {
 				e.SetError(fixError(0, kInvalidLightIdBytes, gohue.NoSuchResourceError))
 				return
@@ -233,8 +644,30 @@ func multiSet(
 	}
 }
 
+// restore sets light back to snapshot. Lights that were off get their
+// color and brightness restored before being turned off. The alert in
+// snapshot is not restored since sending it would cancel any alert that
+// the body of CaptureAndRestore started.
+func restore(
+	setter Setter, light int, snapshot *gohue.LightProperties) error {
+	properties := *snapshot
+	properties.Alert = ""
+	if properties.On.Valid && !properties.On.Value {
+		properties.On.Clear()
+		resp, err := setter.Set(light, &properties)
+		if err != nil && !errors.Is(err, gohue.ErrParameterNotModifiable) {
+			return fixError(light, resp, err)
+		}
+		properties = gohue.LightProperties{On: maybe.NewBool(false)}
+	}
+	if resp, err := setter.Set(light, &properties); err != nil {
+		return fixError(light, resp, err)
+	}
+	return nil
+}
+
 func fixError(lightId int, rawResponse []byte, err error) error {
-	if err == gohue.NoSuchResourceError {
+	if errors.Is(err, gohue.NoSuchResourceError) {
 		return &NoSuchLightIdError{LightId: lightId, RawResponse: rawResponse}
 	}
 	if len(rawResponse) > 0 {
@@ -243,6 +676,13 @@ func fixError(lightId int, rawResponse []byte, err error) error {
 	return err
 }
 
+func fixGroupError(groupId int, rawResponse []byte, err error) error {
+	if errors.Is(err, gohue.NoSuchResourceError) {
+		return &NoSuchGroupIdError{GroupId: groupId, RawResponse: rawResponse}
+	}
+	return fixError(groupId, rawResponse, err)
+}
+
 func maybeBlendColor(first, second gohue.MaybeColor, ratio float64) gohue.MaybeColor {
 	if first.Valid && second.Valid {
 		return gohue.NewMaybeColor(first.Blend(second.Color, ratio))
@@ -6,9 +6,11 @@
 package actions_test
 
 import (
+	"context"
 	"errors"
 	"github.com/keep94/gohue"
 	"github.com/keep94/gohue/actions"
+	"github.com/keep94/gohue/gohuetest"
 	"github.com/keep94/maybe"
 	"github.com/keep94/tasks"
 	"reflect"
@@ -69,6 +71,23 @@ func TestGradient(t *testing.T) {
 	verifyAction(t, expected, action)
 }
 
+func TestGradientTransitionTime(t *testing.T) {
+	action := actions.Action{
+		G: &actions.Gradient{
+			Cds: []actions.ColorDuration{
+				{Bri: maybe.NewUint8(0), D: 0},
+				{Bri: maybe.NewUint8(100), D: 1000}},
+			Refresh: 500}}
+	bridgeDefault := maybe.NewUint16(4)
+	verifyTransitionTimes(
+		t,
+		[]maybe.Uint16{bridgeDefault, bridgeDefault, bridgeDefault},
+		action)
+	action.TransitionTime = maybe.NewUint16(10)
+	optIn := maybe.NewUint16(10)
+	verifyTransitionTimes(t, []maybe.Uint16{optIn, optIn, optIn}, action)
+}
+
 func TestGradient2(t *testing.T) {
 	action := actions.Action{
 		G: &actions.Gradient{
@@ -114,6 +133,116 @@ func TestGradient3(t *testing.T) {
 	verifyAction(t, expected, action)
 }
 
+func TestGradientGroup(t *testing.T) {
+	action := actions.Action{
+		Lights: []int{1, 2, 3},
+		G: &actions.Gradient{
+			Cds: []actions.ColorDuration{
+				{Bri: maybe.NewUint8(0), D: 0},
+				{Bri: maybe.NewUint8(100), D: 1000}},
+			Refresh: 500},
+		Group: maybe.NewInt(4),
+		On:    true}
+	expected := []request{
+		{G: 4, Bri: maybe.NewUint8(0), On: maybe.NewBool(true), D: 0},
+		{G: 4, Bri: maybe.NewUint8(50), D: 500},
+		{G: 4, Bri: maybe.NewUint8(100), D: 1000}}
+	verifyAction(t, expected, action)
+	action.Group = maybe.NewInt(0)
+	expected = []request{
+		{L: 0, Bri: maybe.NewUint8(0), On: maybe.NewBool(true), D: 0},
+		{L: 0, Bri: maybe.NewUint8(50), D: 500},
+		{L: 0, Bri: maybe.NewUint8(100), D: 1000}}
+	verifyAction(t, expected, action)
+}
+
+func TestActionGroup(t *testing.T) {
+	action := actions.Action{
+		Series: []*actions.Action{
+			{On: true, Group: maybe.NewInt(2)},
+			{Alert: gohue.AlertSelect, Group: maybe.NewInt(0)}}}
+	expected := []request{
+		{G: 2, On: maybe.NewBool(true)},
+		{L: 0, Alert: gohue.AlertSelect}}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	tasks.RunForTesting(action.AsTask(context, []int{1, 3}), clock)
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+}
+
+func TestActionGroupNoGroupSetter(t *testing.T) {
+	action := actions.Action{On: true, Group: maybe.NewInt(2)}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := lightSetterForTesting{
+		&setterForTesting{clock: clock, now: kNow}}
+	err := tasks.RunForTesting(action.AsTask(context, nil), clock)
+	if err != actions.ErrGroupSetter {
+		t.Errorf("Expected ErrGroupSetter, got %v", err)
+	}
+	if out := len(context.s.requests); out != 0 {
+		t.Errorf("Expected no requests, got %d", out)
+	}
+}
+
+func TestNoSuchGroupIdError(t *testing.T) {
+	action := actions.Action{On: true, Group: maybe.NewInt(7)}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{
+		err:      &gohue.BridgeError{ErrorId: 3},
+		response: ([]byte)("hello"),
+		clock:    clock,
+		now:      kNow}
+	err := tasks.RunForTesting(action.AsTask(context, nil), clock)
+	noSuchGroupIdError, isNoSuchGroupIdErr := err.(*actions.NoSuchGroupIdError)
+	if !isNoSuchGroupIdErr {
+		t.Fatalf("Expected a NoSuchGroupIdError, got %v", err)
+	}
+	if out := noSuchGroupIdError.GroupId; out != 7 {
+		t.Errorf("Expected 7, got %d", out)
+	}
+}
+
+func TestGradientLoop(t *testing.T) {
+	action := actions.Action{
+		G: &actions.Gradient{
+			Cds: []actions.ColorDuration{
+				{Bri: maybe.NewUint8(0), D: 0},
+				{Bri: maybe.NewUint8(100), D: 1000},
+				{Bri: maybe.NewUint8(200), D: 2000}},
+			Refresh: 500,
+			Mode:    actions.GradientLoop}}
+	expected := []request{
+		{Bri: maybe.NewUint8(0), D: 0},
+		{Bri: maybe.NewUint8(50), D: 500},
+		{Bri: maybe.NewUint8(100), D: 1000},
+		{Bri: maybe.NewUint8(150), D: 1500},
+		{Bri: maybe.NewUint8(200), D: 2000},
+		{Bri: maybe.NewUint8(100), D: 2500},
+		{Bri: maybe.NewUint8(0), D: 3000},
+		{Bri: maybe.NewUint8(50), D: 3500}}
+	verifyEndedAction(t, expected, action)
+}
+
+func TestGradientPingPong(t *testing.T) {
+	action := actions.Action{
+		G: &actions.Gradient{
+			Cds: []actions.ColorDuration{
+				{Bri: maybe.NewUint8(0), D: 0},
+				{Bri: maybe.NewUint8(100), D: 1000}},
+			Refresh: 500,
+			Mode:    actions.GradientPingPong}}
+	expected := []request{
+		{Bri: maybe.NewUint8(0), D: 0},
+		{Bri: maybe.NewUint8(50), D: 500},
+		{Bri: maybe.NewUint8(100), D: 1000},
+		{Bri: maybe.NewUint8(50), D: 1500},
+		{Bri: maybe.NewUint8(0), D: 2000},
+		{Bri: maybe.NewUint8(50), D: 2500}}
+	verifyEndedAction(t, expected, action)
+}
+
 func TestOnColor(t *testing.T) {
 	action := actions.Action{
 		On: true, C: gohue.NewMaybeColor(gohue.NewColor(0.4, 0.2))}
@@ -154,6 +283,44 @@ func TestColorOnly(t *testing.T) {
 	verifyAction(t, expected, action)
 }
 
+func TestOnWithColor(t *testing.T) {
+	action := actions.OnWithColor(gohue.Red, maybe.NewUint8(200))
+	expected := []request{
+		{L: 2, On: maybe.NewBool(true), D: 0},
+		{L: 2,
+			C:   gohue.NewMaybeColor(gohue.Red),
+			Bri: maybe.NewUint8(200),
+			D:   0}}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	tasks.RunForTesting(action.AsTask(context, []int{2}), clock)
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+	expectedTransitionTimes := []maybe.Uint16{maybe.NewUint16(0), {}}
+	if !reflect.DeepEqual(expectedTransitionTimes, context.transitionTimes) {
+		t.Errorf(
+			"Expected %v, got %v",
+			expectedTransitionTimes,
+			context.transitionTimes)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	action := actions.Action{
+		Series: []*actions.Action{
+			{On: true, Effect: gohue.EffectColorLoop},
+			{Sleep: 3000},
+			{Alert: gohue.AlertSelect}}}
+	expected := []request{
+		{L: 0, On: maybe.NewBool(true), Effect: gohue.EffectColorLoop, D: 0},
+		{L: 0, Alert: gohue.AlertSelect, D: 3000}}
+	verifyAction(t, expected, action)
+	if out := action.Touches(); out != actions.AttrOn|actions.AttrAlert|actions.AttrEffect {
+		t.Errorf("Unexpected attributes %d", out)
+	}
+}
+
 func TestRepeat(t *testing.T) {
 	action := actions.Action{On: true, Repeat: 3}
 	expected := []request{
@@ -238,6 +405,24 @@ func TestNoSuchLightIdError(t *testing.T) {
 	}
 }
 
+func TestNoSuchLightIdBridgeError(t *testing.T) {
+	action := actions.Action{On: true}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{
+		err:      &gohue.BridgeError{ErrorId: 3},
+		response: ([]byte)("hello"),
+		clock:    clock,
+		now:      kNow}
+	err := tasks.RunForTesting(action.AsTask(context, []int{5}), clock)
+	noSuchLightIdError, isNoSuchLightIdErr := err.(*actions.NoSuchLightIdError)
+	if !isNoSuchLightIdErr {
+		t.Fatal("Expected a NoSuchLightIdError.")
+	}
+	if out := noSuchLightIdError.LightId; out != 5 {
+		t.Errorf("Expected 5, got %d", out)
+	}
+}
+
 func TestNoZeroLightId(t *testing.T) {
 	action := actions.Action{On: true}
 	clock := &tasks.ClockForTesting{Current: kNow}
@@ -259,12 +444,220 @@ func TestNoZeroLightId(t *testing.T) {
 	}
 }
 
+func TestTouches(t *testing.T) {
+	action := actions.Action{
+		Series: []*actions.Action{
+			{On: true},
+			{Sleep: 3000},
+			{G: &actions.Gradient{
+				Cds: []actions.ColorDuration{
+					{C: gohue.NewMaybeColor(gohue.Red), D: 0},
+					{C: gohue.NewMaybeColor(gohue.Blue), D: 1000}},
+				Refresh: 500}}}}
+	if out := action.Touches(); out != actions.AttrC|actions.AttrOn {
+		t.Errorf("Expected %d, got %d", actions.AttrC|actions.AttrOn, out)
+	}
+	action = actions.Action{
+		Parallel: []*actions.Action{
+			{Off: true},
+			{Bri: maybe.NewUint8(100)}}}
+	if out := action.Touches(); out != actions.AttrBri|actions.AttrOff {
+		t.Errorf("Expected %d, got %d", actions.AttrBri|actions.AttrOff, out)
+	}
+	action = actions.Action{Sleep: 3000}
+	if out := action.Touches(); out != 0 {
+		t.Errorf("Expected 0, got %d", out)
+	}
+}
+
+func TestCaptureAndRestore(t *testing.T) {
+	getter := getterForTesting{
+		2: {C: gohue.NewMaybeColor(gohue.Blue),
+			Bri: maybe.NewUint8(50),
+			On:  maybe.NewBool(true)},
+		3: {C: gohue.NewMaybeColor(gohue.Green),
+			Bri: maybe.NewUint8(70),
+			On:  maybe.NewBool(false)}}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	action := actions.CaptureAndRestore(
+		getter,
+		context,
+		[]int{2, 3},
+		&actions.Action{On: true, C: gohue.NewMaybeColor(gohue.Red)})
+	expected := []request{
+		{L: 2, C: gohue.NewMaybeColor(gohue.Red), On: maybe.NewBool(true)},
+		{L: 3, C: gohue.NewMaybeColor(gohue.Red), On: maybe.NewBool(true)},
+		{L: 2,
+			C:   gohue.NewMaybeColor(gohue.Blue),
+			Bri: maybe.NewUint8(50),
+			On:  maybe.NewBool(true)},
+		{L: 3, C: gohue.NewMaybeColor(gohue.Green), Bri: maybe.NewUint8(70)},
+		{L: 3, On: maybe.NewBool(false)}}
+	if err := tasks.RunForTesting(action.AsTask(context, nil), clock); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+	if out := action.Touches(); out != actions.AttrC|actions.AttrOn {
+		t.Errorf("Expected %d, got %d", actions.AttrC|actions.AttrOn, out)
+	}
+}
+
+func TestCaptureAndRestoreAlert(t *testing.T) {
+	getter := getterForTesting{
+		2: {Bri: maybe.NewUint8(50),
+			On:    maybe.NewBool(true),
+			Alert: gohue.AlertNone}}
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	action := actions.CaptureAndRestore(
+		getter,
+		context,
+		[]int{2},
+		&actions.Action{Alert: gohue.AlertLSelect})
+	expected := []request{
+		{L: 2, Alert: gohue.AlertLSelect},
+		{L: 2, Bri: maybe.NewUint8(50), On: maybe.NewBool(true)}}
+	if err := tasks.RunForTesting(action.AsTask(context, nil), clock); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+}
+
+func TestCaptureAndRestoreNoLights(t *testing.T) {
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	action := actions.CaptureAndRestore(
+		getterForTesting{}, context, nil, &actions.Action{On: true})
+	err := tasks.RunForTesting(action.AsTask(context, nil), clock)
+	if err != actions.ErrCaptureAndRestore {
+		t.Errorf("Expected ErrCaptureAndRestore, got %v", err)
+	}
+	if out := len(context.requests); out != 0 {
+		t.Errorf("Expected no requests, got %d", out)
+	}
+}
+
+func TestCaptureAndRestoreGetError(t *testing.T) {
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	action := actions.CaptureAndRestore(
+		getterForTesting{}, context, []int{4}, &actions.Action{On: true})
+	err := tasks.RunForTesting(action.AsTask(context, nil), clock)
+	if _, ok := err.(*actions.NoSuchLightIdError); !ok {
+		t.Errorf("Expected NoSuchLightIdError, got %v", err)
+	}
+	if out := len(context.requests); out != 0 {
+		t.Errorf("Expected no requests, got %d", out)
+	}
+}
+
+func TestRequireOn(t *testing.T) {
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &getterSetterForTesting{
+		getterForTesting: getterForTesting{
+			1: {On: maybe.NewBool(true)},
+			2: {On: maybe.NewBool(false)},
+			3: {On: maybe.NewBool(true)}},
+		setterForTesting: &setterForTesting{clock: clock, now: kNow}}
+	action := actions.Action{
+		C: gohue.NewMaybeColor(gohue.Red), RequireOn: true}
+	expected := []request{
+		{L: 1, C: gohue.NewMaybeColor(gohue.Red)},
+		{L: 3, C: gohue.NewMaybeColor(gohue.Red)}}
+	err := tasks.RunForTesting(action.AsTask(context, []int{1, 2, 3}), clock)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+}
+
+func TestRequireOnNoGetter(t *testing.T) {
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	action := actions.Action{
+		C: gohue.NewMaybeColor(gohue.Red), RequireOn: true}
+	err := tasks.RunForTesting(action.AsTask(context, []int{1}), clock)
+	if err != actions.ErrRequireOn {
+		t.Errorf("Expected ErrRequireOn, got %v", err)
+	}
+	if out := len(context.requests); out != 0 {
+		t.Errorf("Expected no requests, got %d", out)
+	}
+}
+
+func TestRunActionCancel(t *testing.T) {
+	bridge := gohuetest.NewBridge(1)
+	action := actions.Action{
+		On: true,
+		G: &actions.Gradient{
+			Cds: []actions.ColorDuration{
+				{Bri: maybe.NewUint8(0), D: 0},
+				{Bri: maybe.NewUint8(100), D: time.Second}},
+			Refresh: 10 * time.Millisecond,
+			Mode:    actions.GradientLoop}}
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := actions.RunAction(ctx, &action, bridge, []int{1})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected action to stop promptly, took %v", elapsed)
+	}
+}
+
+func TestEndedBetweenLights(t *testing.T) {
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &endingSetter{
+		setterForTesting: &setterForTesting{clock: clock, now: kNow},
+		maxRequests:      1}
+	action := actions.Action{On: true}
+	task := action.AsTask(context, []int{1, 2, 3})
+	tasks.RunForTesting(tasks.TaskFunc(func(e *tasks.Execution) {
+		context.e = e
+		task.Do(e)
+	}), clock)
+	expected := []request{{L: 1, On: maybe.NewBool(true)}}
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+}
+
+func TestRunAction(t *testing.T) {
+	bridge := gohuetest.NewBridge(1)
+	action := actions.Action{On: true, Bri: maybe.NewUint8(30)}
+	err := actions.RunAction(context.Background(), &action, bridge, []int{1})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	properties, _, _ := bridge.Get(1)
+	if properties.Bri != maybe.NewUint8(30) {
+		t.Errorf("Expected 30, got %v", properties.Bri)
+	}
+	err = actions.RunAction(context.Background(), &action, bridge, []int{2})
+	if _, ok := err.(*actions.NoSuchLightIdError); !ok {
+		t.Errorf("Expected NoSuchLightIdError, got %v", err)
+	}
+}
+
 type request struct {
-	L   int
-	C   gohue.MaybeColor
-	Bri maybe.Uint8
-	On  maybe.Bool
-	D   time.Duration
+	L      int
+	G      int
+	C      gohue.MaybeColor
+	Bri    maybe.Uint8
+	On     maybe.Bool
+	Alert  gohue.Alert
+	Effect gohue.Effect
+	D      time.Duration
 }
 
 type setterForTesting struct {
@@ -273,6 +666,9 @@ type setterForTesting struct {
 	clock    *tasks.ClockForTesting
 	now      time.Time
 	requests []request
+
+	// The transition time of each request sent to Set.
+	transitionTimes []maybe.Uint16
 }
 
 func (s *setterForTesting) Set(lightId int, p *gohue.LightProperties) (result []byte, err error) {
@@ -281,13 +677,104 @@ func (s *setterForTesting) Set(lightId int, p *gohue.LightProperties) (result []
 	r.C = p.C
 	r.Bri = p.Bri
 	r.On = p.On
+	r.Alert = p.Alert
+	r.Effect = p.Effect
 	r.D = s.clock.Current.Sub(s.now)
 	s.requests = append(s.requests, r)
+	s.transitionTimes = append(s.transitionTimes, p.TransitionTime)
 	err = s.err
 	result = s.response
 	return
 }
 
+type getterForTesting map[int]*gohue.LightProperties
+
+func (g getterForTesting) Get(lightId int) (
+	properties *gohue.LightProperties, response []byte, err error) {
+	properties, ok := g[lightId]
+	if !ok {
+		return nil, ([]byte)("no such light"), gohue.NoSuchResourceError
+	}
+	return
+}
+
+type getterSetterForTesting struct {
+	getterForTesting
+	*setterForTesting
+}
+
+func (s *setterForTesting) SetGroup(groupId int, p *gohue.LightProperties) (result []byte, err error) {
+	var r request
+	r.G = groupId
+	r.C = p.C
+	r.Bri = p.Bri
+	r.On = p.On
+	r.Alert = p.Alert
+	r.Effect = p.Effect
+	r.D = s.clock.Current.Sub(s.now)
+	s.requests = append(s.requests, r)
+	err = s.err
+	result = s.response
+	return
+}
+
+// lightSetterForTesting implements Setter but not GroupSetter.
+type lightSetterForTesting struct {
+	s *setterForTesting
+}
+
+func (l lightSetterForTesting) Set(
+	lightId int, p *gohue.LightProperties) ([]byte, error) {
+	return l.s.Set(lightId, p)
+}
+
+// endingSetter ends its execution after a certain number of requests.
+type endingSetter struct {
+	*setterForTesting
+	e           *tasks.Execution
+	maxRequests int
+}
+
+func (s *endingSetter) Set(lightId int, p *gohue.LightProperties) (result []byte, err error) {
+	result, err = s.setterForTesting.Set(lightId, p)
+	if len(s.requests) >= s.maxRequests {
+		s.e.End()
+	}
+	return
+}
+
+// verifyEndedAction verifies an action that runs until its execution is
+// ended. The execution is ended after the expected number of requests.
+func verifyEndedAction(
+	t *testing.T, expected []request, action actions.Action) {
+	t.Helper()
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &endingSetter{
+		setterForTesting: &setterForTesting{clock: clock, now: kNow},
+		maxRequests:      len(expected)}
+	task := action.AsTask(context, nil)
+	tasks.RunForTesting(tasks.TaskFunc(func(e *tasks.Execution) {
+		context.e = e
+		task.Do(e)
+	}), clock)
+	if !reflect.DeepEqual(expected, context.requests) {
+		t.Errorf("Expected %v, got %v", expected, context.requests)
+	}
+}
+
+// verifyTransitionTimes verifies the transition time of each request
+// that action sends.
+func verifyTransitionTimes(
+	t *testing.T, expected []maybe.Uint16, action actions.Action) {
+	t.Helper()
+	clock := &tasks.ClockForTesting{Current: kNow}
+	context := &setterForTesting{clock: clock, now: kNow}
+	tasks.RunForTesting(action.AsTask(context, nil), clock)
+	if !reflect.DeepEqual(expected, context.transitionTimes) {
+		t.Errorf("Expected %v, got %v", expected, context.transitionTimes)
+	}
+}
+
 func verifyAction(t *testing.T, expected []request, action actions.Action) {
 	clock := &tasks.ClockForTesting{Current: kNow}
 	context := &setterForTesting{clock: clock, now: kNow}
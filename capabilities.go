@@ -0,0 +1,136 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/keep94/gohue/json_structs"
+	"math"
+	"net/http"
+)
+
+const (
+	kMinMired = 153
+	kMaxMired = 500
+)
+
+var (
+	// kXYCapabilities are the capabilities assumed for lights with
+	// unknown capabilities.
+	kXYCapabilities = Capabilities{XY: true}
+)
+
+// Capabilities describes the ways a light accepts color. Set uses the
+// capabilities of a light to decide how to send color to it. Set sends
+// xy color if the light supports it. Otherwise, Set converts the color to
+// hue and saturation or to the closest color temperature.
+// If a light supports none of these, Set does not send color to it.
+type Capabilities struct {
+
+	// True if the light accepts xy color.
+	XY bool
+
+	// True if the light accepts hue and saturation.
+	HueSat bool
+
+	// True if the light accepts color temperature.
+	Ct bool
+
+	// The gamut of the light. If set, Set clamps colors to this gamut
+	// before sending them to the light. The zero value means unknown in
+	// which case Set sends colors as is.
+	Gamut Gamut
+}
+
+// GetCapabilities gets the capabilities of a light by reading its
+// attributes from the hue bridge. lightId is the ID of the light.
+// response and err work the same way as in Get.
+func (c *Context) GetCapabilities(lightId int) (
+	capabilities Capabilities, response []byte, err error) {
+	request := &http.Request{
+		Method: "GET",
+		URL:    c.getLightUrl(lightId),
+	}
+	if response, err = c.do(context.Background(), request); err != nil {
+		return
+	}
+	var jsonLight json_structs.LightState
+	if err = json.Unmarshal(response, &jsonLight); err != nil {
+		if err = toError(response); err == nil {
+			err = unexpectedResponseError(response)
+		}
+		return
+	}
+	switch jsonLight.Type {
+	case "Extended color light":
+		capabilities = Capabilities{XY: true, HueSat: true, Ct: true}
+	case "Color light":
+		capabilities = Capabilities{XY: true, HueSat: true}
+	case "Color temperature light":
+		capabilities = Capabilities{Ct: true}
+	}
+	if jsonLight.Capabilities != nil {
+		control := &jsonLight.Capabilities.Control
+		if control.ColorGamutType != "" {
+			capabilities.XY = true
+			capabilities.HueSat = true
+		}
+		switch control.ColorGamutType {
+		case "A":
+			capabilities.Gamut = GamutA
+		case "B":
+			capabilities.Gamut = GamutB
+		case "C":
+			capabilities.Gamut = GamutC
+		}
+		if control.Ct != nil {
+			capabilities.Ct = true
+		}
+	}
+	return
+}
+
+// lightCapabilities returns the capabilities of a light from
+// Options.LightCapabilities.
+func (c *Context) lightCapabilities(lightId int) Capabilities {
+	if capabilities, ok := c.capabilities[lightId]; ok {
+		return capabilities
+	}
+	return kXYCapabilities
+}
+
+// addColor adds color to jsonMap in the way that capabilities allows.
+func addColor(
+	jsonMap map[string]interface{},
+	color Color,
+	capabilities Capabilities) {
+	if capabilities.Gamut != (Gamut{}) {
+		color = color.ClampToGamut(capabilities.Gamut)
+	}
+	switch {
+	case capabilities.XY:
+		jsonMap["xy"] = []float64{color.X(), color.Y()}
+	case capabilities.HueSat:
+		h, s, _ := rgbToHSB(color.rgb())
+		jsonMap["hue"] = uint16(h/360.0*65535.0 + 0.5)
+		jsonMap["sat"] = uint8(s*254.0 + 0.5)
+	case capabilities.Ct:
+		jsonMap["ct"] = color.mired()
+	}
+}
+
+// mired returns the color temperature in mireds closest to this color
+// using McCamy's approximation.
+func (c Color) mired() uint16 {
+	n := (c.X() - 0.3320) / (0.1858 - c.Y())
+	kelvin := 449.0*n*n*n + 3525.0*n*n + 6823.3*n + 5520.33
+	if kelvin <= 0 {
+		return kMaxMired
+	}
+	mired := math.Min(math.Max(1000000.0/kelvin, kMinMired), kMaxMired)
+	return uint16(mired + 0.5)
+}
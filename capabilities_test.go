@@ -0,0 +1,100 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"errors"
+	"github.com/keep94/gohue"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestSetUsesCapabilities(t *testing.T) {
+	var body []byte
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{
+			LightCapabilities: map[int]gohue.Capabilities{
+				2: {HueSat: true, Ct: true},
+				3: {Ct: true},
+				4: {},
+				5: {XY: true, Gamut: gohue.GamutB}}})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.C.Set(gohue.NewColor(0.7006, 0.2993))
+	verifySetBody(t, context, 1, &properties, &body, `{"xy":[0.7006,0.2993]}`)
+	verifySetBody(t, context, 2, &properties, &body, `{"hue":65534,"sat":254}`)
+	properties.C.Set(gohue.NewColor(0.3127, 0.3290))
+	verifySetBody(t, context, 3, &properties, &body, `{"ct":154}`)
+	properties.C.Set(gohue.NewColor(0.4476, 0.4074))
+	verifySetBody(t, context, 3, &properties, &body, `{"ct":350}`)
+	verifySetBody(t, context, 4, &properties, &body, `{}`)
+	properties.C.Set(gohue.NewColor(0.2151, 0.7106))
+	verifySetBody(t, context, 5, &properties, &body, `{"xy":[0.409,0.518]}`)
+}
+
+func TestGetCapabilities(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/user/lights/1":
+				w.Write(([]byte)(`{"type":"Extended color light"}`))
+			case "/api/user/lights/2":
+				w.Write(([]byte)(`{"type":"Color temperature light"}`))
+			case "/api/user/lights/3":
+				w.Write(([]byte)(`{"type":"Some light","capabilities":` +
+					`{"control":{"colorgamuttype":"C"}}}`))
+			default:
+				w.Write(([]byte)(`[{"error":{"type":3}}]`))
+			}
+		}, nil)
+	defer server.Close()
+	verifyCapabilities(
+		t, context, 1, gohue.Capabilities{XY: true, HueSat: true, Ct: true})
+	verifyCapabilities(t, context, 2, gohue.Capabilities{Ct: true})
+	verifyCapabilities(
+		t,
+		context,
+		3,
+		gohue.Capabilities{XY: true, HueSat: true, Gamut: gohue.GamutC})
+	_, _, err := context.GetCapabilities(4)
+	if !errors.Is(err, gohue.NoSuchResourceError) {
+		t.Errorf("Expected NoSuchResourceError, got %v", err)
+	}
+}
+
+func verifySetBody(
+	t *testing.T,
+	context *gohue.Context,
+	lightId int,
+	properties *gohue.LightProperties,
+	body *[]byte,
+	expected string) {
+	t.Helper()
+	if _, err := context.Set(lightId, properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, expected, string(*body))
+}
+
+func verifyCapabilities(
+	t *testing.T,
+	context *gohue.Context,
+	lightId int,
+	expected gohue.Capabilities) {
+	t.Helper()
+	actual, _, err := context.GetCapabilities(lightId)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Complementary returns the complement of this color, the color with the
+// opposite hue.
+func (c Color) Complementary() Color {
+	return c.rotateHue(180.0)
+}
+
+// Analogous returns the two colors whose hues are degrees away from the hue
+// of this color on either side. The first color returned has its hue
+// rotated by -degrees; the second has its hue rotated by degrees.
+func (c Color) Analogous(degrees float64) (Color, Color) {
+	return c.rotateHue(-degrees), c.rotateHue(degrees)
+}
+
+// rotateHue returns this color with its hue rotated by degrees keeping
+// saturation and brightness the same.
+func (c Color) rotateHue(degrees float64) Color {
+	if c.y == 0 {
+		return c
+	}
+	h, s, v := rgbToHSB(c.rgb())
+	h = math.Mod(h+degrees, 360.0)
+	if h < 0 {
+		h += 360.0
+	}
+	return newColorFromRGB(hsbToRGB(h, s, v))
+}
+
+// rgb returns the gamma corrected red, green, and blue components of this
+// color at full brightness. Each component is between 0.0 and 1.0. The
+// conversion follows the formulas Philips publishes for hue lights.
+// The returned components are all 0 if the y value of this color is 0.
+func (c Color) rgb() (r, g, b float64) {
+	x, y := c.X(), c.Y()
+	if y == 0 {
+		return
+	}
+	bigY := 1.0
+	bigX := (bigY / y) * x
+	bigZ := (bigY / y) * (1.0 - x - y)
+	r = bigX*1.656492 - bigY*0.354851 - bigZ*0.255038
+	g = -bigX*0.707196 + bigY*1.655397 + bigZ*0.036152
+	b = bigX*0.051713 - bigY*0.121364 + bigZ*1.011530
+	r, g, b = math.Max(r, 0.0), math.Max(g, 0.0), math.Max(b, 0.0)
+	if max := math.Max(r, math.Max(g, b)); max > 1.0 {
+		r, g, b = r/max, g/max, b/max
+	}
+	return reverseGamma(r), reverseGamma(g), reverseGamma(b)
+}
+
+// newColorFromRGB returns the color with the given gamma corrected red,
+// green, and blue components. Each component is between 0.0 and 1.0.
+// newColorFromRGB returns White if all components are 0.
+func newColorFromRGB(r, g, b float64) Color {
+	r, g, b = gamma(r), gamma(g), gamma(b)
+	bigX := r*0.664511 + g*0.154324 + b*0.162028
+	bigY := r*0.283881 + g*0.668433 + b*0.047685
+	bigZ := r*0.000088 + g*0.072310 + b*0.986039
+	sum := bigX + bigY + bigZ
+	if sum == 0 {
+		return White
+	}
+	return NewColor(bigX/sum, bigY/sum)
+}
+
+func gamma(v float64) float64 {
+	if v > 0.04045 {
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return v / 12.92
+}
+
+func reverseGamma(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1.0/2.4) - 0.055
+}
+
+// rgbToHSB converts red, green, and blue components to hue, saturation,
+// and brightness. h is in degrees between 0.0 and 360.0; s and v are
+// between 0.0 and 1.0.
+func rgbToHSB(r, g, b float64) (h, s, v float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	v = max
+	delta := max - min
+	if max == 0 || delta == 0 {
+		return
+	}
+	s = delta / max
+	switch max {
+	case r:
+		h = (g - b) / delta
+	case g:
+		h = 2.0 + (b-r)/delta
+	default:
+		h = 4.0 + (r-g)/delta
+	}
+	h *= 60.0
+	if h < 0 {
+		h += 360.0
+	}
+	return
+}
+
+// hsbToRGB is the inverse of rgbToHSB.
+func hsbToRGB(h, s, v float64) (r, g, b float64) {
+	if s == 0 {
+		return v, v, v
+	}
+	h = math.Mod(h, 360.0) / 60.0
+	i := math.Floor(h)
+	f := h - i
+	p := v * (1.0 - s)
+	q := v * (1.0 - s*f)
+	t := v * (1.0 - s*(1.0-f))
+	switch int(i) {
+	case 0:
+		return v, t, p
+	case 1:
+		return q, v, p
+	case 2:
+		return p, v, t
+	case 3:
+		return p, q, v
+	case 4:
+		return t, p, v
+	default:
+		return v, p, q
+	}
+}
+
+// NewColorFromRGB returns the Color for the given red, green, and blue
+// components. The conversion follows the formulas Philips publishes for
+// hue lights. Since Colors do not store brightness, colors that differ
+// only in brightness such as #FF0000 and #800000 map to the same Color.
+func NewColorFromRGB(r, g, b uint8) Color {
+	return newColorFromRGB(
+		float64(r)/255.0, float64(g)/255.0, float64(b)/255.0)
+}
+
+// NewColorFromHex returns the Color for a hex color code such as
+// "#FF8000". The leading '#' is optional.
+func NewColorFromHex(s string) (Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return Color{}, fmt.Errorf("gohue: Invalid hex color %q.", s)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("gohue: Invalid hex color %q.", s)
+	}
+	return NewColorFromRGB(
+		uint8(value>>16), uint8(value>>8), uint8(value)), nil
+}
+
+// RGB returns the red, green, and blue components of this color at full
+// brightness. Round trips through RGB are not exact because Colors do not
+// store brightness and because colors outside the RGB gamut are clamped,
+// but the hue and saturation come out close.
+func (c Color) RGB() (r, g, b uint8) {
+	fr, fg, fb := c.rgb()
+	return toUint8(fr), toUint8(fg), toUint8(fb)
+}
+
+// Hex returns the hex color code of this color such as "#FF8000".
+// Hex uses the same conversion as RGB.
+func (c Color) Hex() string {
+	r, g, b := c.RGB()
+	return fmt.Sprintf("#%02X%02X%02X", r, g, b)
+}
+
+func toUint8(v float64) uint8 {
+	return uint8(math.Min(math.Max(v, 0.0), 1.0)*255.0 + 0.5)
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"github.com/keep94/gohue"
+	"math"
+	"testing"
+)
+
+var (
+	// Pure red, green, blue, and their complements in RGB space.
+	kRGBRed     = gohue.NewColor(0.7006, 0.2993)
+	kRGBGreen   = gohue.NewColor(0.1724, 0.7468)
+	kRGBBlue    = gohue.NewColor(0.1355, 0.0399)
+	kRGBCyan    = gohue.NewColor(0.1513, 0.3425)
+	kRGBMagenta = gohue.NewColor(0.3855, 0.1546)
+	kRGBYellow  = gohue.NewColor(0.4442, 0.5166)
+)
+
+func TestComplementary(t *testing.T) {
+	verifyColorClose(t, kRGBCyan, kRGBRed.Complementary())
+	verifyColorClose(t, kRGBMagenta, kRGBGreen.Complementary())
+	verifyColorClose(t, kRGBYellow, kRGBBlue.Complementary())
+	verifyColorClose(t, gohue.Red, gohue.Red.Complementary().Complementary())
+}
+
+func TestAnalogous(t *testing.T) {
+	left, right := kRGBRed.Analogous(60.0)
+	verifyColorClose(t, kRGBMagenta, left)
+	verifyColorClose(t, kRGBYellow, right)
+	left, right = kRGBBlue.Analogous(0.0)
+	verifyColorClose(t, kRGBBlue, left)
+	verifyColorClose(t, kRGBBlue, right)
+}
+
+func TestRGB(t *testing.T) {
+	verifyDominant(t, "red", gohue.Red, 0)
+	verifyDominant(t, "green", gohue.Green, 1)
+	verifyDominant(t, "blue", gohue.Blue, 2)
+	verifyColorClose(t, kRGBRed, gohue.NewColorFromRGB(255, 0, 0))
+	verifyColorClose(t, kRGBRed, gohue.NewColorFromRGB(128, 0, 0))
+	verifyColorClose(t, kRGBCyan, gohue.NewColorFromRGB(0, 255, 255))
+	if r, g, b := gohue.NewColorFromRGB(0, 0, 255).RGB(); r != 0 || g != 0 || b != 255 {
+		t.Errorf("Expected (0, 0, 255), got (%d, %d, %d)", r, g, b)
+	}
+}
+
+func TestHex(t *testing.T) {
+	for _, hex := range []string{"#FF0000", "#00FF00", "#0000FF", "#FF8000"} {
+		c, err := gohue.NewColorFromHex(hex)
+		if err != nil {
+			t.Fatalf("Got error %v", err)
+		}
+		verifyString(t, hex, c.Hex())
+	}
+	c, err := gohue.NewColorFromHex("ff0000")
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyColorClose(t, kRGBRed, c)
+	for _, bad := range []string{"", "#FF00", "#GG0000", "#FF00001"} {
+		if _, err := gohue.NewColorFromHex(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func verifyDominant(
+	t *testing.T, name string, c gohue.Color, channel int) {
+	t.Helper()
+	r, g, b := c.RGB()
+	rgb := []uint8{r, g, b}
+	for i := range rgb {
+		if i != channel && rgb[i] >= rgb[channel] {
+			t.Errorf("Expected %s to stay %s, got %s", c, name, c.Hex())
+		}
+	}
+}
+
+func verifyColorClose(t *testing.T, expected, actual gohue.Color) {
+	t.Helper()
+	if math.Abs(expected.X()-actual.X()) > 0.005 ||
+		math.Abs(expected.Y()-actual.Y()) > 0.005 {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/keep94/gohue/json_structs"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	kDiscoveryUrl           = "https://discovery.meethue.com"
+	kDefaultDiscoverTimeout = 10 * time.Second
+	kNUPnPTimeout           = 5 * time.Second
+	kSSDPTimeout            = 3 * time.Second
+	kSSDPAddress            = "239.255.255.250:1900"
+	kSSDPSearch             = "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: ssdp:all\r\n\r\n"
+)
+
+// BridgeInfo describes a hue bridge found on the local network.
+type BridgeInfo struct {
+
+	// The ID of the hue bridge.
+	Id string
+
+	// The private ip address of the hue bridge. Pass to NewContext.
+	InternalIpAddress string
+}
+
+// DiscoverBridges finds the hue bridges on the local network so that
+// callers need not hard code the ip address of their hue bridge.
+// DiscoverBridges works like DiscoverBridgesWithOptions with nil options.
+func DiscoverBridges() ([]BridgeInfo, error) {
+	return DiscoverBridgesWithOptions(nil)
+}
+
+// DiscoverBridgesWithOptions finds the hue bridges on the local network.
+// It asks the Philips N-UPnP service at https://discovery.meethue.com.
+// If that service cannot be reached, it falls back to an SSDP search of
+// the local network. If the N-UPnP service reports no bridges,
+// DiscoverBridgesWithOptions returns an empty slice and no error. If the
+// N-UPnP service cannot be reached and the SSDP search finds no bridges,
+// DiscoverBridgesWithOptions returns the error from the N-UPnP service. Only the Timeout field of options
+// is used. Timeout bounds the whole discovery. The N-UPnP request gets
+// at most 5 seconds of it, and the SSDP search listens for replies for
+// at most 3 seconds. If options is nil or has no timeout, discovery times
+// out after 10 seconds.
+func DiscoverBridgesWithOptions(options *Options) ([]BridgeInfo, error) {
+	if options == nil {
+		options = kDefaultOptions
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = kDefaultDiscoverTimeout
+	}
+	nupnpTimeout, ssdpTimeout := discoveryTimeouts(timeout)
+	nupnpCtx, cancel := context.WithTimeout(
+		context.Background(), nupnpTimeout)
+	defer cancel()
+	result, err := discoverNUPnP(nupnpCtx, http.DefaultClient, kDiscoveryUrl)
+	if err == nil {
+		return result, nil
+	}
+	ssdpCtx, ssdpCancel := context.WithTimeout(
+		context.Background(), ssdpTimeout)
+	defer ssdpCancel()
+	// An empty result means no bridges were found, so report the N-UPnP
+	// error unless the SSDP search found a bridge.
+	if result, ssdpErr := discoverSSDP(ssdpCtx); ssdpErr == nil &&
+		len(result) > 0 {
+		return result, nil
+	}
+	return nil, err
+}
+
+// discoveryTimeouts splits timeout between the N-UPnP request and the
+// SSDP search so that a blocked N-UPnP service still leaves time for the
+// SSDP search.
+func discoveryTimeouts(timeout time.Duration) (
+	nupnpTimeout, ssdpTimeout time.Duration) {
+	ssdpTimeout = kSSDPTimeout
+	if timeout < 2*kSSDPTimeout {
+		ssdpTimeout = timeout / 2
+	}
+	nupnpTimeout = timeout - ssdpTimeout
+	if nupnpTimeout > kNUPnPTimeout {
+		nupnpTimeout = kNUPnPTimeout
+	}
+	return
+}
+
+func discoverNUPnP(
+	ctx context.Context, client *http.Client, discoveryUrl string) (
+	[]BridgeInfo, error) {
+	request, err := http.NewRequest("GET", discoveryUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var respBuffer bytes.Buffer
+	if _, err := respBuffer.ReadFrom(
+		io.LimitReader(resp.Body, kDefaultMaxResponseBytes)); err != nil {
+		return nil, err
+	}
+	var jsonBridges []json_structs.BridgeInfo
+	if err := json.Unmarshal(respBuffer.Bytes(), &jsonBridges); err != nil {
+		return nil, unexpectedResponseError(respBuffer.Bytes())
+	}
+	result := make([]BridgeInfo, 0, len(jsonBridges))
+	for _, jsonBridge := range jsonBridges {
+		result = append(result, BridgeInfo{
+			Id:                jsonBridge.Id,
+			InternalIpAddress: jsonBridge.InternalIpAddress})
+	}
+	return result, nil
+}
+
+// discoverSSDP finds hue bridges by multicasting an SSDP search and
+// collecting replies until ctx is done.
+func discoverSSDP(ctx context.Context) ([]BridgeInfo, error) {
+	addr, err := net.ResolveUDPAddr("udp4", kSSDPAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteTo(([]byte)(kSSDPSearch), addr); err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	result := make([]BridgeInfo, 0)
+	seen := make(map[string]bool)
+	buffer := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			// The read deadline ends the search.
+			return result, nil
+		}
+		bridge, ok := parseSSDPResponse(buffer[:n])
+		if ok && !seen[bridge.InternalIpAddress] {
+			seen[bridge.InternalIpAddress] = true
+			result = append(result, bridge)
+		}
+	}
+}
+
+// parseSSDPResponse returns the hue bridge that sent an SSDP response.
+// It returns false if the response did not come from a hue bridge.
+func parseSSDPResponse(response []byte) (BridgeInfo, bool) {
+	httpResp, err := http.ReadResponse(
+		bufio.NewReader(bytes.NewReader(response)), nil)
+	if err != nil {
+		return BridgeInfo{}, false
+	}
+	httpResp.Body.Close()
+	bridgeId := httpResp.Header.Get("Hue-Bridgeid")
+	if bridgeId == "" &&
+		!strings.Contains(httpResp.Header.Get("Server"), "IpBridge") {
+		return BridgeInfo{}, false
+	}
+	location, err := url.Parse(httpResp.Header.Get("Location"))
+	if err != nil || location.Hostname() == "" {
+		return BridgeInfo{}, false
+	}
+	return BridgeInfo{
+		Id: bridgeId, InternalIpAddress: location.Hostname()}, true
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiscoverNUPnP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"id":"001788fffe100491",` +
+				`"internalipaddress":"192.168.2.23"},` +
+				`{"id":"001788fffe09a168",` +
+				`"internalipaddress":"192.168.88.252","port":443}]`))
+		}))
+	defer server.Close()
+	bridges, err := discoverNUPnP(
+		context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expected := []BridgeInfo{
+		{Id: "001788fffe100491", InternalIpAddress: "192.168.2.23"},
+		{Id: "001788fffe09a168", InternalIpAddress: "192.168.88.252"}}
+	if !reflect.DeepEqual(expected, bridges) {
+		t.Errorf("Expected %v, got %v", expected, bridges)
+	}
+}
+
+func TestDiscoverNUPnPNoBridges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[]`))
+		}))
+	defer server.Close()
+	bridges, err := discoverNUPnP(
+		context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if bridges == nil || len(bridges) != 0 {
+		t.Errorf("Expected empty slice, got %v", bridges)
+	}
+}
+
+func TestDiscoveryTimeouts(t *testing.T) {
+	nupnp, ssdp := discoveryTimeouts(10 * time.Second)
+	if nupnp != 5*time.Second || ssdp != 3*time.Second {
+		t.Errorf("Expected 5s and 3s, got %v and %v", nupnp, ssdp)
+	}
+	nupnp, ssdp = discoveryTimeouts(7 * time.Second)
+	if nupnp != 4*time.Second || ssdp != 3*time.Second {
+		t.Errorf("Expected 4s and 3s, got %v and %v", nupnp, ssdp)
+	}
+	nupnp, ssdp = discoveryTimeouts(2 * time.Second)
+	if nupnp != time.Second || ssdp != time.Second {
+		t.Errorf("Expected 1s and 1s, got %v and %v", nupnp, ssdp)
+	}
+}
+
+func TestParseSSDPResponse(t *testing.T) {
+	bridge, ok := parseSSDPResponse(([]byte)("HTTP/1.1 200 OK\r\n" +
+		"LOCATION: http://192.168.1.5:80/description.xml\r\n" +
+		"SERVER: Linux/3.14.0 UPnP/1.0 IpBridge/1.26.0\r\n" +
+		"hue-bridgeid: 001788FFFE100491\r\n\r\n"))
+	expected := BridgeInfo{
+		Id: "001788FFFE100491", InternalIpAddress: "192.168.1.5"}
+	if !ok || bridge != expected {
+		t.Errorf("Expected %v, got %v", expected, bridge)
+	}
+	_, ok = parseSSDPResponse(([]byte)("HTTP/1.1 200 OK\r\n" +
+		"LOCATION: http://192.168.1.9:80/description.xml\r\n" +
+		"SERVER: SomeRouter UPnP/1.0\r\n\r\n"))
+	if ok {
+		t.Error("Expected response from non bridge to be ignored.")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"math"
+)
+
+// Gamut is the triangle of colors in the XY color space that a light can
+// show. Programs should typically store and pass Gamuts as values.
+type Gamut struct {
+	Red   Color
+	Green Color
+	Blue  Color
+}
+
+const (
+	kGamutTolerance = 1.0 / maxu16
+)
+
+var (
+	// GamutA is the gamut of older LivingColors lights such as Bloom,
+	// Aura, and LightStrips.
+	GamutA = Gamut{
+		Red:   NewColor(0.704, 0.296),
+		Green: NewColor(0.2151, 0.7106),
+		Blue:  NewColor(0.138, 0.08),
+	}
+
+	// GamutB is the gamut of older hue bulbs such as LCT001.
+	GamutB = Gamut{
+		Red:   NewColor(0.675, 0.322),
+		Green: NewColor(0.409, 0.518),
+		Blue:  NewColor(0.167, 0.04),
+	}
+
+	// GamutC is the gamut of newer hue bulbs and LightStrips plus.
+	GamutC = Gamut{
+		Red:   NewColor(0.6915, 0.3083),
+		Green: NewColor(0.17, 0.7),
+		Blue:  NewColor(0.1532, 0.0475),
+	}
+)
+
+// Contains returns true if c is inside this gamut or on its edge.
+// Since Colors are rounded, colors within 0.0001 of an edge count as
+// being on the edge.
+func (g Gamut) Contains(c Color) bool {
+	x, y := c.X(), c.Y()
+	hasNeg, hasPos := false, false
+	for _, edge := range g.edges() {
+		d := signedDistance(edge[0], edge[1], x, y)
+		hasNeg = hasNeg || d < -kGamutTolerance
+		hasPos = hasPos || d > kGamutTolerance
+	}
+	return !(hasNeg && hasPos)
+}
+
+// ClampToGamut returns this color if it is inside gamut. Otherwise it
+// returns the color inside gamut closest to this color.
+func (c Color) ClampToGamut(gamut Gamut) Color {
+	if gamut.Contains(c) {
+		return c
+	}
+	x, y := c.X(), c.Y()
+	bestX, bestY, bestDist := 0.0, 0.0, math.Inf(1)
+	for _, edge := range gamut.edges() {
+		px, py := closestOnSegment(edge[0], edge[1], x, y)
+		if dist := math.Hypot(px-x, py-y); dist < bestDist {
+			bestX, bestY, bestDist = px, py, dist
+		}
+	}
+	return NewColor(bestX, bestY)
+}
+
+func (g Gamut) edges() [3][2]Color {
+	return [3][2]Color{
+		{g.Red, g.Green}, {g.Green, g.Blue}, {g.Blue, g.Red}}
+}
+
+// signedDistance returns the distance from (x, y) to the line through
+// a and b. The sign tells which side of the line (x, y) is on.
+func signedDistance(a, b Color, x, y float64) float64 {
+	dx, dy := b.X()-a.X(), b.Y()-a.Y()
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return 0
+	}
+	return (dx*(y-a.Y()) - dy*(x-a.X())) / length
+}
+
+// closestOnSegment returns the point on the line segment from a to b
+// closest to (x, y).
+func closestOnSegment(a, b Color, x, y float64) (float64, float64) {
+	dx, dy := b.X()-a.X(), b.Y()-a.Y()
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return a.X(), a.Y()
+	}
+	t := ((x-a.X())*dx + (y-a.Y())*dy) / lengthSquared
+	t = math.Min(math.Max(t, 0.0), 1.0)
+	return a.X() + t*dx, a.Y() + t*dy
+}
@@ -0,0 +1,40 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"github.com/keep94/gohue"
+	"testing"
+)
+
+func TestClampToGamutInside(t *testing.T) {
+	c := gohue.NewColor(0.4, 0.3)
+	if out := c.ClampToGamut(gohue.GamutB); out != c {
+		t.Errorf("Expected %s, got %s", c, out)
+	}
+	if out := gohue.GamutB.Red.ClampToGamut(gohue.GamutB); out != gohue.GamutB.Red {
+		t.Errorf("Expected %s, got %s", gohue.GamutB.Red, out)
+	}
+}
+
+func TestClampToGamutOutsideEdge(t *testing.T) {
+	// Below the edge from blue (0.138, 0.08) to red (0.704, 0.296) in
+	// gamut A.
+	c := gohue.NewColor(0.421, 0.1)
+	verifyColorClose(
+		t, gohue.NewColor(0.3917, 0.1768), c.ClampToGamut(gohue.GamutA))
+	if !gohue.GamutA.Contains(c.ClampToGamut(gohue.GamutA)) {
+		t.Error("Expected clamped color to be in gamut.")
+	}
+}
+
+func TestClampToGamutOutsideVertex(t *testing.T) {
+	// Saturated green lies beyond the green vertex of gamut B.
+	c := gohue.NewColor(0.2151, 0.7106)
+	verifyColorClose(t, gohue.GamutB.Green, c.ClampToGamut(gohue.GamutB))
+	c = gohue.NewColor(0.8, 0.2)
+	verifyColorClose(t, gohue.GamutC.Red, c.ClampToGamut(gohue.GamutC))
+}
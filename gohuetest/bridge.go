@@ -0,0 +1,173 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohuetest
+
+import (
+	"fmt"
+	"github.com/keep94/gohue"
+	"sort"
+	"sync"
+)
+
+// Bridge simulates a hue bridge by keeping the state of its lights in
+// memory. Bridge implements the Setter and Getter interfaces of the
+// actions package. Like a real hue bridge, setting the color or
+// brightness of a light that is off fails with a *gohue.BridgeError that
+// errors.Is reports as gohue.ErrParameterNotModifiable. Bridge ignores
+// transition times and alerts since they do not persist on a real hue
+// bridge. Bridge instances are safe to use with multiple goroutines.
+type Bridge struct {
+	mu     sync.Mutex
+	lights map[int]*gohue.LightProperties
+}
+
+// NewBridge returns a new Bridge with the given light ids. Each light
+// starts off at the dimmest brightness with no color.
+func NewBridge(lightIds ...int) *Bridge {
+	result := &Bridge{lights: make(map[int]*gohue.LightProperties)}
+	for _, id := range lightIds {
+		result.lights[id] = newLightProperties()
+	}
+	return result
+}
+
+// Seed sets the state of a light adding the light if it does not already
+// exist. Unlike Set, Seed can set the color and brightness of a light that
+// is off. Like Set, Seed ignores transition times and alerts.
+func (b *Bridge) Seed(lightId int, properties *gohue.LightProperties) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	light, ok := b.lights[lightId]
+	if !ok {
+		light = newLightProperties()
+		b.lights[lightId] = light
+	}
+	update(light, properties)
+}
+
+// LightIds returns the light ids of this instance in ascending order.
+func (b *Bridge) LightIds() []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make([]int, 0, len(b.lights))
+	for id := range b.lights {
+		result = append(result, id)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Set sets the properties of a light. 0 means all lights. If the light
+// does not exist, Set returns a *gohue.BridgeError that errors.Is reports
+// as gohue.NoSuchResourceError. When setting all lights, lights that are
+// off keep their color and brightness, but no error is returned.
+func (b *Bridge) Set(lightId int, properties *gohue.LightProperties) (
+	response []byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lightId == 0 {
+		for id, light := range b.lights {
+			set(id, light, properties)
+		}
+		return successResponse(0), nil
+	}
+	light, ok := b.lights[lightId]
+	if !ok {
+		return noSuchResource(lightId)
+	}
+	return set(lightId, light, properties)
+}
+
+// Get gets the properties of a light. If the light does not exist, Get
+// returns a *gohue.BridgeError that errors.Is reports as
+// gohue.NoSuchResourceError.
+func (b *Bridge) Get(lightId int) (
+	properties *gohue.LightProperties, response []byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	light, ok := b.lights[lightId]
+	if !ok {
+		response, err = noSuchResource(lightId)
+		return nil, response, err
+	}
+	result := *light
+	return &result, successResponse(lightId), nil
+}
+
+func newLightProperties() *gohue.LightProperties {
+	var result gohue.LightProperties
+	result.On.Set(false)
+	result.Bri.Set(gohue.Dim)
+	return &result
+}
+
+func set(
+	lightId int,
+	light *gohue.LightProperties,
+	properties *gohue.LightProperties) (response []byte, err error) {
+	if properties.On.Valid {
+		light.On = properties.On
+	}
+	if !light.On.Value && (properties.C.Valid || properties.Hue.Valid ||
+		properties.Sat.Valid || properties.Bri.Valid || properties.Ct.Valid) {
+		return notModifiable(lightId)
+	}
+	update(light, properties)
+	return successResponse(lightId), nil
+}
+
+func update(light, properties *gohue.LightProperties) {
+	if properties.C.Valid {
+		light.C = properties.C
+	} else {
+		if properties.Hue.Valid {
+			light.Hue = properties.Hue
+		}
+		if properties.Sat.Valid {
+			light.Sat = properties.Sat
+		}
+	}
+	if properties.Bri.Valid {
+		light.Bri = properties.Bri
+	}
+	if properties.Ct.Valid {
+		light.Ct = properties.Ct
+	}
+	if properties.Effect != "" {
+		light.Effect = properties.Effect
+	}
+	if properties.On.Valid {
+		light.On = properties.On
+	}
+}
+
+func successResponse(lightId int) []byte {
+	return ([]byte)(fmt.Sprintf(
+		`[{"success":{"/lights/%d/state":"ok"}}]`, lightId))
+}
+
+func notModifiable(lightId int) (response []byte, err error) {
+	return bridgeError(&gohue.BridgeError{
+		ErrorId:     201,
+		Address:     fmt.Sprintf("/lights/%d/state", lightId),
+		Description: "Device is set to off."})
+}
+
+func noSuchResource(lightId int) (response []byte, err error) {
+	address := fmt.Sprintf("/lights/%d", lightId)
+	return bridgeError(&gohue.BridgeError{
+		ErrorId:     3,
+		Address:     address,
+		Description: fmt.Sprintf("resource, %s, not available", address)})
+}
+
+// bridgeError returns err along with the raw response a hue bridge sends
+// when reporting err.
+func bridgeError(err *gohue.BridgeError) ([]byte, error) {
+	return ([]byte)(fmt.Sprintf(
+		`[{"error":{"type":%d,"address":%q,"description":%q}}]`,
+		err.ErrorId, err.Address, err.Description)), err
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohuetest_test
+
+import (
+	"errors"
+	"github.com/keep94/gohue"
+	"github.com/keep94/gohue/actions"
+	"github.com/keep94/gohue/gohuetest"
+	"github.com/keep94/maybe"
+	"github.com/keep94/tasks"
+	"reflect"
+	"testing"
+)
+
+func TestBridge(t *testing.T) {
+	bridge := gohuetest.NewBridge(1, 2)
+	bridge.Seed(3, &gohue.LightProperties{
+		C:   gohue.NewMaybeColor(gohue.Blue),
+		Bri: maybe.NewUint8(40),
+		On:  maybe.NewBool(true)})
+	if out := bridge.LightIds(); !reflect.DeepEqual(out, []int{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3], got %v", out)
+	}
+	action := actions.Action{
+		Series: []*actions.Action{
+			{Lights: []int{1}, On: true},
+			{C: gohue.NewMaybeColor(gohue.Red), RequireOn: true}}}
+	if err := tasks.Run(action.AsTask(bridge, []int{1, 2, 3})); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyLight(t, bridge, 1, &gohue.LightProperties{
+		C:   gohue.NewMaybeColor(gohue.Red),
+		Bri: maybe.NewUint8(gohue.Dim),
+		On:  maybe.NewBool(true)})
+	verifyLight(t, bridge, 2, &gohue.LightProperties{
+		Bri: maybe.NewUint8(gohue.Dim),
+		On:  maybe.NewBool(false)})
+	verifyLight(t, bridge, 3, &gohue.LightProperties{
+		C:   gohue.NewMaybeColor(gohue.Red),
+		Bri: maybe.NewUint8(40),
+		On:  maybe.NewBool(true)})
+}
+
+func TestBridgeErrors(t *testing.T) {
+	bridge := gohuetest.NewBridge(1)
+	if _, _, err := bridge.Get(2); !errors.Is(err, gohue.NoSuchResourceError) {
+		t.Errorf("Expected NoSuchResourceError, got %v", err)
+	}
+	var properties gohue.LightProperties
+	properties.Bri.Set(100)
+	_, err := bridge.Set(2, &properties)
+	if !errors.Is(err, gohue.NoSuchResourceError) {
+		t.Errorf("Expected NoSuchResourceError, got %v", err)
+	}
+	if _, ok := err.(*gohue.BridgeError); !ok {
+		t.Errorf("Expected *gohue.BridgeError, got %T", err)
+	}
+	_, err = bridge.Set(1, &properties)
+	if !errors.Is(err, gohue.ErrParameterNotModifiable) {
+		t.Errorf("Expected ErrParameterNotModifiable, got %v", err)
+	}
+	if _, err := bridge.Set(0, &properties); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	properties.On.Set(true)
+	if _, err := bridge.Set(1, &properties); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	verifyLight(t, bridge, 1, &gohue.LightProperties{
+		Bri: maybe.NewUint8(100), On: maybe.NewBool(true)})
+}
+
+func verifyLight(
+	t *testing.T,
+	bridge *gohuetest.Bridge,
+	lightId int,
+	expected *gohue.LightProperties) {
+	t.Helper()
+	actual, _, err := bridge.Get(lightId)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if *expected != *actual {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
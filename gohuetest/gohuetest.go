@@ -0,0 +1,41 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+// Package gohuetest contains helpers for testing code that uses gohue.
+package gohuetest
+
+import (
+	"github.com/keep94/gohue"
+	"math"
+)
+
+const (
+	// Colors store X and Y as multiples of this quantum.
+	kQuantum = 1.0 / 10000.0
+
+	// Allow for floating point error on top of one quantum.
+	kTolerance = kQuantum * 1.5
+)
+
+// ColorsEqual returns true if a and b are the same color give or take the
+// rounding that gohue.NewColor does. gohue.NewColor rounds X and Y to the
+// nearest 0.0001, so a color computed one way, say by blending, may be off
+// by 0.0001 from the same color computed another way, say by reading it
+// back with gohue.Context.Get. Comparing such colors with == fails
+// even though they are the same to the hue bridge.
+func ColorsEqual(a, b gohue.Color) bool {
+	return math.Abs(a.X()-b.X()) <= kTolerance &&
+		math.Abs(a.Y()-b.Y()) <= kTolerance
+}
+
+// MaybeColorsEqual works like ColorsEqual for MaybeColor values.
+// Two MaybeColor values that represent nothing are always equal
+// regardless of the Color they hold.
+func MaybeColorsEqual(a, b gohue.MaybeColor) bool {
+	if !a.Valid || !b.Valid {
+		return a.Valid == b.Valid
+	}
+	return ColorsEqual(a.Color, b.Color)
+}
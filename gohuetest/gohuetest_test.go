@@ -0,0 +1,39 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohuetest_test
+
+import (
+	"github.com/keep94/gohue"
+	"github.com/keep94/gohue/gohuetest"
+	"testing"
+)
+
+func TestColorsEqual(t *testing.T) {
+	if !gohuetest.ColorsEqual(
+		gohue.NewColor(0.40004, 0.6), gohue.NewColor(0.40006, 0.6)) {
+		t.Error("Expected colors one quantum apart to be equal.")
+	}
+	if gohuetest.ColorsEqual(
+		gohue.NewColor(0.4, 0.6), gohue.NewColor(0.4003, 0.6)) {
+		t.Error("Expected colors three quanta apart to differ.")
+	}
+}
+
+func TestMaybeColorsEqual(t *testing.T) {
+	nothing := gohue.MaybeColor{Color: gohue.White}
+	if !gohuetest.MaybeColorsEqual(nothing, gohue.MaybeColor{}) {
+		t.Error("Expected nothing to equal nothing.")
+	}
+	if gohuetest.MaybeColorsEqual(
+		nothing, gohue.NewMaybeColor(gohue.White)) {
+		t.Error("Expected nothing to differ from a color.")
+	}
+	if !gohuetest.MaybeColorsEqual(
+		gohue.NewMaybeColor(gohue.NewColor(0.30004, 0.2)),
+		gohue.NewMaybeColor(gohue.NewColor(0.30006, 0.2))) {
+		t.Error("Expected colors one quantum apart to be equal.")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/keep94/gohue/json_structs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// GroupInfo describes a group of lights such as a room or zone.
+type GroupInfo struct {
+
+	// The ID of the group. Pass to Context.SetGroup.
+	Id int
+
+	// The human readable name of the group.
+	Name string
+
+	// The type of the group such as "Room" or "Zone".
+	Type string
+
+	// The IDs of the lights in the group in ascending order.
+	Lights []int
+}
+
+// Groups returns the groups on the hue bridge keyed by group ID.
+// Groups does not include group 0, the group of all lights, since the
+// hue bridge does not list it.
+func (c *Context) Groups() (map[int]GroupInfo, error) {
+	request := &http.Request{
+		Method: "GET",
+		URL:    c.getAllGroupsUrl(),
+	}
+	response, err := c.do(context.Background(), request)
+	if err != nil {
+		return nil, err
+	}
+	var jsonGroups json_structs.Groups
+	if err := json.Unmarshal(response, &jsonGroups); err != nil {
+		if err := toError(response); err != nil {
+			return nil, err
+		}
+		return nil, unexpectedResponseError(response)
+	}
+	result := make(map[int]GroupInfo, len(jsonGroups))
+	for idStr, jsonGroup := range jsonGroups {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		lights := make([]int, 0, len(jsonGroup.Lights))
+		for _, lightIdStr := range jsonGroup.Lights {
+			if lightId, err := strconv.Atoi(lightIdStr); err == nil {
+				lights = append(lights, lightId)
+			}
+		}
+		sort.Ints(lights)
+		result[id] = GroupInfo{
+			Id:     id,
+			Name:   jsonGroup.Name,
+			Type:   jsonGroup.Type,
+			Lights: lights,
+		}
+	}
+	return result, nil
+}
+
+func (c *Context) getAllGroupsUrl() *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   c.ipAddress,
+		Path:   fmt.Sprintf("%s/%s/groups", c.basePath, c.userId),
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"github.com/keep94/gohue"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/user/groups" {
+				w.Write(([]byte)(`[{"error":{"type":3}}]`))
+				return
+			}
+			w.Write(([]byte)(`{"1":{"name":"Living room","type":"Room",` +
+				`"lights":["3","1","2"]},` +
+				`"2":{"name":"Upstairs","type":"Zone","lights":[]}}`))
+		}, nil)
+	defer server.Close()
+	groups, err := context.Groups()
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expected := map[int]gohue.GroupInfo{
+		1: {Id: 1, Name: "Living room", Type: "Room", Lights: []int{1, 2, 3}},
+		2: {Id: 2, Name: "Upstairs", Type: "Zone", Lights: []int{}}}
+	if !reflect.DeepEqual(expected, groups) {
+		t.Errorf("Expected %v, got %v", expected, groups)
+	}
+}
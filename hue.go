@@ -8,6 +8,7 @@ package gohue
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,6 +18,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -28,12 +30,64 @@ var (
 	Dim = uint8(0)
 )
 
+// BridgeError is an error that the hue bridge reports. Context methods
+// return *BridgeError when the hue bridge reports an error. For
+// compatibility, errors.Is reports a *BridgeError with ErrorId 3 as
+// NoSuchResourceError, one with ErrorId 201 as ErrParameterNotModifiable,
+// and any other as GeneralError.
+type BridgeError struct {
+
+	// The error type such as 3 for resource not available, 7 for invalid
+	// value, or 101 for link button not pressed.
+	// See http://developers.meethue.com.
+	ErrorId int
+
+	// The resource that the error is about such as "/lights/1/state/bri".
+	Address string
+
+	// The description of the error from the hue bridge.
+	Description string
+}
+
+func (e *BridgeError) Error() string {
+	return fmt.Sprintf(
+		"gohue: Bridge error %d at %s: %s", e.ErrorId, e.Address, e.Description)
+}
+
+// Is supports errors.Is. See BridgeError.
+func (e *BridgeError) Is(target error) bool {
+	switch e.ErrorId {
+	case 3:
+		return target == NoSuchResourceError
+	case 201:
+		return target == ErrParameterNotModifiable
+	}
+	return target == GeneralError
+}
+
 var (
-	// Indicates that the light ID is not found.
+	// Indicates that the light ID is not found. Context methods report
+	// this as a *BridgeError; use errors.Is to test for it.
 	NoSuchResourceError = errors.New("gohue: No such resource error.")
 
 	// Indicates that some general error happened.
 	GeneralError = errors.New("gohue: General error.")
+
+	// Indicates that a parameter such as color or brightness cannot be
+	// changed because the light is off. Turning the light on first and
+	// retrying usually fixes this.
+	ErrParameterNotModifiable = errors.New(
+		"gohue: Parameter not modifiable while light is off.")
+
+	// Indicates that the response did not come from a hue bridge. This
+	// often means that the IP address of the hue bridge is wrong.
+	// Errors returned for unexpected responses include a snippet of the
+	// response and wrap this error, so use errors.Is to test for it.
+	ErrUnexpectedResponse = errors.New("gohue: Unexpected response.")
+
+	// Indicates that the response from the hue bridge exceeded
+	// Options.MaxResponseBytes.
+	ErrResponseTooLarge = errors.New("gohue: Response too large.")
 )
 
 var (
@@ -53,6 +107,18 @@ const (
 	maxu16 = float64(10000.0)
 )
 
+const (
+	kDefaultWatchInterval    = time.Second
+	kDefaultMaxResponseBytes = 10 * 1024 * 1024
+	kMaxSnippetBytes         = 64
+	kDefaultBasePath         = "/api"
+	kDefaultUserAgent        = "gohue (github.com/keep94/gohue)"
+
+	kDefaultMaxConcurrentRequests = 3
+	kDefaultMaxRequestsPerSecond  = 10
+	kDefaultRetryBackoff          = 100 * time.Millisecond
+)
+
 var (
 	kDefaultOptions = &Options{}
 )
@@ -66,6 +132,9 @@ type Color struct {
 
 // NewColor returns a new Color. x and y are the coordinates of the color
 // in the color XY space. x and y are between 0.0 and 1.0 inclusive.
+// NewColor rounds x and y to the nearest 0.0001, so colors computed in
+// different ways may differ slightly when compared with ==. Tests can use
+// gohuetest.ColorsEqual to compare colors allowing for this rounding.
 func NewColor(x, y float64) Color {
 	return Color{x: uint16(x*maxu16 + 0.5), y: uint16(y*maxu16 + 0.5)}
 }
@@ -93,6 +162,26 @@ func (c Color) Blend(other Color, ratio float64) Color {
 		c.Y()*invratio+other.Y()*ratio)
 }
 
+// MiredFromKelvin converts a color temperature in kelvin to mireds, the
+// unit that LightProperties.Ct uses. For example, 2700K, a warm white,
+// is about 370 mireds. The result is clamped to 153-500 mireds, the range
+// the hue bridge accepts, so kelvin values below 2000K give 500 and values
+// above about 6500K give 153. kelvin must be positive; MiredFromKelvin
+// returns 0 otherwise.
+func MiredFromKelvin(kelvin int) uint16 {
+	if kelvin <= 0 {
+		return 0
+	}
+	mired := (1000000 + kelvin/2) / kelvin
+	if mired < kMinMired {
+		return kMinMired
+	}
+	if mired > kMaxMired {
+		return kMaxMired
+	}
+	return uint16(mired)
+}
+
 // MaybeColor instances represent a Color or nothing. The zero value is nothing.
 type MaybeColor struct {
 	Color
@@ -124,11 +213,49 @@ func (m MaybeColor) String() string {
 	return fmt.Sprintf("Just %s", m.Color)
 }
 
+// Alert is a temporary change to the state of a light used to get
+// attention.
+type Alert string
+
+const (
+	// AlertNone stops any alert.
+	AlertNone Alert = "none"
+
+	// AlertSelect makes the light breathe once.
+	AlertSelect Alert = "select"
+
+	// AlertLSelect makes the light breathe for 15 seconds.
+	AlertLSelect Alert = "lselect"
+)
+
+// Effect is a dynamic effect on a light.
+type Effect string
+
+const (
+	// EffectNone stops any effect.
+	EffectNone Effect = "none"
+
+	// EffectColorLoop makes the light cycle through all hues using the
+	// current brightness and saturation.
+	EffectColorLoop Effect = "colorloop"
+)
+
 // LightProperies represents the properties of a light.
 type LightProperties struct {
 	// C is the Color. Nothing means leave color as-is.
 	C MaybeColor
 
+	// Hue is the hue from 0 to 65535 where both 0 and 65535 are red.
+	// Nothing means leave hue as is. Context.Set ignores Hue and Sat when
+	// C is set, just as the hue bridge prefers xy color over hue and
+	// saturation. Context.Get populates only if the light reports a hue.
+	Hue maybe.Uint16
+
+	// Sat is the saturation from 0, white, to 254, most saturated.
+	// Nothing means leave saturation as is. Context.Get populates only
+	// if the light reports a saturation.
+	Sat maybe.Uint8
+
 	// Bri is the brightness. Nothing means leave brightness as is.
 	Bri maybe.Uint8
 
@@ -136,25 +263,109 @@ type LightProperties struct {
 	// means leave the on/off state as is.
 	On maybe.Bool
 
+	// Ct is the color temperature in mireds. Nothing means leave color
+	// temperature as is. Context.Get() populates only if the light
+	// reports a color temperature. See MiredFromKelvin.
+	Ct maybe.Uint16
+
+	// Alert is the alert. Empty means leave alert as is.
+	Alert Alert
+
+	// Effect is the effect. Empty means leave effect as is.
+	Effect Effect
+
 	// The transition time in multiples of 100ms. Nothing means the default
-	// transition time. See http://developers.meethue.com.
+	// transition time which is Options.DefaultTransitionTime if set or
+	// the hue bridge's own default otherwise.
+	// See http://developers.meethue.com.
 	// Used only with Context.Set(). Context.Get() does not populate.
 	TransitionTime maybe.Uint16
 }
 
 // Context represents a connection with a hue bridge.
+// Context instances are safe to use with multiple goroutines. A Context
+// never changes after it is created, so any state added to it later
+// must either be immutable or be guarded by a mutex.
 type Context struct {
-	ipAddress string
-	userId    string
-	allUrl    *url.URL
-	client    *http.Client
+	ipAddress             string
+	userId                string
+	basePath              string
+	client                *http.Client
+	watchInterval         time.Duration
+	defaultTransitionTime maybe.Uint16
+	maxResponseBytes      int64
+	requestTimeout        time.Duration
+	capabilities          map[int]Capabilities
+	userAgent             string
+	maxConcurrentRequests int
+	limiter               *rateLimiter
+	timeout               time.Duration
+	maxRetries            int
+	retryBackoff          time.Duration
 }
 
 // Options contains optional settings for Context instance creation.
 type Options struct {
 	// Operations that take longer than this will fail with an error.
 	// Zero or negative values means no timeout specified.
+	// This timeout applies to dialing and to the life of each connection
+	// to the hue bridge. See RequestTimeout to bound individual requests.
 	Timeout time.Duration
+
+	// Each request to the hue bridge, including reading the response,
+	// fails with an error if it takes longer than this. Zero or negative
+	// values means no timeout specified.
+	RequestTimeout time.Duration
+
+	// The path under which the hue bridge serves its API. Hue compatible
+	// bridges and proxies sometimes serve the API under a different path.
+	// Empty means "/api".
+	BasePath string
+
+	// Watch polls the hue bridge this often. Zero or negative values
+	// means poll once a second.
+	WatchInterval time.Duration
+
+	// Set uses this transition time, in multiples of 100ms, when the
+	// properties passed to it do not specify one. Nothing means use the
+	// hue bridge's default. Gradients in the actions package always send
+	// an explicit transition time, so they bypass this setting unless
+	// the Action sets the same value in its TransitionTime field.
+	DefaultTransitionTime maybe.Uint16
+
+	// Responses from the hue bridge longer than this many bytes fail with
+	// ErrResponseTooLarge. Zero or negative values means 10MiB.
+	MaxResponseBytes int64
+
+	// The capabilities of lights by light ID. Set uses these to decide
+	// how to send color to a light. Lights not in this map are assumed
+	// to accept xy color. See Context.GetCapabilities.
+	LightCapabilities map[int]Capabilities
+
+	// The User-Agent header sent with each request to the hue bridge.
+	// Empty means "gohue (github.com/keep94/gohue)".
+	UserAgent string
+
+	// The most requests SetMultiple sends at the same time. Zero or
+	// negative values means 3.
+	MaxConcurrentRequests int
+
+	// The most requests per second SetMultiple sends. The hue bridge
+	// handles about 10 commands per second. Zero or negative values
+	// means 10.
+	MaxRequestsPerSecond int
+
+	// The most times to retry a request that fails because of a
+	// connection error or a 5xx response. Errors that the hue bridge
+	// reports such as NoSuchResourceError are not retried. Retries stop
+	// early if they would go past Timeout. If the last attempt still gets
+	// a 5xx response, Context methods return an error with the status
+	// code. Zero or negative values means no retries.
+	MaxRetries int
+
+	// How long to wait before the first retry. The wait doubles with each
+	// retry after that. Zero or negative values means 100ms.
+	RetryBackoff time.Duration
 }
 
 // NewContext creates a new Context instance. ipAddress is the private ip
@@ -174,20 +385,59 @@ func NewContextWithOptions(
 	if options == nil {
 		options = kDefaultOptions
 	}
-	allUrl := &url.URL{
-		Scheme: "http",
-		Host:   ipAddress,
-		Path:   fmt.Sprintf("/api/%s/groups/0/action", userId),
+	basePath := strings.TrimSuffix(options.BasePath, "/")
+	if basePath == "" {
+		basePath = kDefaultBasePath
 	}
 	var client http.Client
 	if options.Timeout > 0 {
 		client.Transport = &http.Transport{Dial: timeoutDialer(options.Timeout)}
 	}
+	watchInterval := options.WatchInterval
+	if watchInterval <= 0 {
+		watchInterval = kDefaultWatchInterval
+	}
+	maxResponseBytes := options.MaxResponseBytes
+	if maxResponseBytes <= 0 {
+		maxResponseBytes = kDefaultMaxResponseBytes
+	}
+	userAgent := options.UserAgent
+	if userAgent == "" {
+		userAgent = kDefaultUserAgent
+	}
+	maxConcurrentRequests := options.MaxConcurrentRequests
+	if maxConcurrentRequests <= 0 {
+		maxConcurrentRequests = kDefaultMaxConcurrentRequests
+	}
+	maxRequestsPerSecond := options.MaxRequestsPerSecond
+	if maxRequestsPerSecond <= 0 {
+		maxRequestsPerSecond = kDefaultMaxRequestsPerSecond
+	}
+	requestInterval := time.Second / time.Duration(maxRequestsPerSecond)
+	retryBackoff := options.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = kDefaultRetryBackoff
+	}
+	capabilities := make(map[int]Capabilities, len(options.LightCapabilities))
+	for id, lightCapabilities := range options.LightCapabilities {
+		capabilities[id] = lightCapabilities
+	}
 	return &Context{
-		ipAddress: ipAddress,
-		userId:    userId,
-		allUrl:    allUrl,
-		client:    &client}
+		ipAddress:             ipAddress,
+		userId:                userId,
+		basePath:              basePath,
+		client:                &client,
+		watchInterval:         watchInterval,
+		defaultTransitionTime: options.DefaultTransitionTime,
+		maxResponseBytes:      maxResponseBytes,
+		requestTimeout:        options.RequestTimeout,
+		capabilities:          capabilities,
+		userAgent:             userAgent,
+		maxConcurrentRequests: maxConcurrentRequests,
+		limiter:               newRateLimiter(requestInterval),
+		timeout:               options.Timeout,
+		maxRetries:            options.MaxRetries,
+		retryBackoff:          retryBackoff}
 }
 
 // Set sets the properties of a light. lightId is the ID of the light to set.
@@ -198,19 +448,52 @@ func NewContextWithOptions(
 // applications, it is enough just to look at err.
 func (c *Context) Set(
 	lightId int, properties *LightProperties) (response []byte, err error) {
+	return c.put(
+		c.lightUrl(lightId), c.lightCapabilities(lightId), properties)
+}
+
+// SetGroup sets the properties of all the lights in a group in a single
+// request. groupId is the ID of the group. 0 means all lights.
+// response and err work the same way as in Set.
+func (c *Context) SetGroup(
+	groupId int, properties *LightProperties) (response []byte, err error) {
+	return c.put(c.groupUrl(groupId), kXYCapabilities, properties)
+}
+
+func (c *Context) put(
+	u *url.URL,
+	capabilities Capabilities,
+	properties *LightProperties) (response []byte, err error) {
 	jsonMap := make(map[string]interface{})
 	if properties.C.Valid {
-		jsonMap["xy"] = []float64{
-			properties.C.X(), properties.C.Y()}
+		addColor(jsonMap, properties.C.Color, capabilities)
+	} else {
+		if properties.Hue.Valid {
+			jsonMap["hue"] = properties.Hue.Value
+		}
+		if properties.Sat.Valid {
+			jsonMap["sat"] = properties.Sat.Value
+		}
 	}
 	if properties.Bri.Valid {
 		jsonMap["bri"] = properties.Bri.Value
 	}
+	if properties.Ct.Valid {
+		jsonMap["ct"] = properties.Ct.Value
+	}
 	if properties.On.Valid {
 		jsonMap["on"] = properties.On.Value
 	}
+	if properties.Alert != "" {
+		jsonMap["alert"] = properties.Alert
+	}
+	if properties.Effect != "" {
+		jsonMap["effect"] = properties.Effect
+	}
 	if properties.TransitionTime.Valid {
 		jsonMap["transitiontime"] = properties.TransitionTime.Value
+	} else if c.defaultTransitionTime.Valid {
+		jsonMap["transitiontime"] = c.defaultTransitionTime.Value
 	}
 	var reqBuffer []byte
 	if reqBuffer, err = json.Marshal(jsonMap); err != nil {
@@ -218,21 +501,16 @@ func (c *Context) Set(
 	}
 	request := &http.Request{
 		Method:        "PUT",
-		URL:           c.lightUrl(lightId),
+		URL:           u,
 		ContentLength: int64(len(reqBuffer)),
 		Body:          simpleReadCloser{bytes.NewReader(reqBuffer)},
+		GetBody: func() (io.ReadCloser, error) {
+			return simpleReadCloser{bytes.NewReader(reqBuffer)}, nil
+		},
 	}
-	client := c.client
-	var resp *http.Response
-	if resp, err = client.Do(request); err != nil {
+	if response, err = c.do(context.Background(), request); err != nil {
 		return
 	}
-	defer resp.Body.Close()
-	var respBuffer bytes.Buffer
-	if _, err = respBuffer.ReadFrom(resp.Body); err != nil {
-		return
-	}
-	response = respBuffer.Bytes()
 	err = toError(response)
 	return
 }
@@ -249,55 +527,159 @@ func (c *Context) Get(lightId int) (
 		Method: "GET",
 		URL:    c.getLightUrl(lightId),
 	}
-	client := c.client
-	var resp *http.Response
-	if resp, err = client.Do(request); err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	var respBuffer bytes.Buffer
-	if _, err = respBuffer.ReadFrom(resp.Body); err != nil {
+	if response, err = c.do(context.Background(), request); err != nil {
 		return
 	}
-	response = respBuffer.Bytes()
 	var jsonProps json_structs.LightState
 	if err = json.Unmarshal(response, &jsonProps); err != nil {
-		err = toError(response)
+		if err = toError(response); err == nil {
+			err = unexpectedResponseError(response)
+		}
 		return
 	}
 	if jsonProps.State != nil {
-		state := jsonProps.State
-		color := MaybeColor{Valid: false, Color: White}
-		if len(jsonProps.State.XY) == 2 {
-			jsonColor := state.XY
-			color = NewMaybeColor(NewColor(jsonColor[0], jsonColor[1]))
-		}
-		properties = &LightProperties{
-			C:   color,
-			Bri: maybe.NewUint8(state.Bri),
-			On:  maybe.NewBool(state.On)}
+		properties = toLightProperties(jsonProps.State)
 	} else {
 		err = GeneralError
 	}
 	return
 }
 
+// do sends request to the hue bridge and returns the raw response. If the
+// hue bridge answers with a 5xx status, do returns the raw response along
+// with an error carrying the status code.
+func (c *Context) do(
+	ctx context.Context, request *http.Request) (response []byte, err error) {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	request.Header.Set("User-Agent", c.userAgent)
+	var statusCode int
+	response, statusCode, err = c.doWithRetries(ctx, request)
+	if err == nil && statusCode >= 500 {
+		err = fmt.Errorf("gohue: HTTP %d.", statusCode)
+	}
+	return
+}
+
+// doWithRetries sends request to the hue bridge retrying connection
+// errors and 5xx responses. It returns the raw response and HTTP status
+// code of the last attempt.
+func (c *Context) doWithRetries(
+	ctx context.Context, request *http.Request) (
+	response []byte, statusCode int, err error) {
+	start := time.Now()
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		response, statusCode, err = c.doOnce(ctx, request)
+		retryable := (err != nil && err != ErrResponseTooLarge) ||
+			statusCode >= 500
+		if !retryable || attempt >= c.maxRetries || ctx.Err() != nil {
+			return
+		}
+		if c.timeout > 0 && time.Since(start)+backoff > c.timeout {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if request.GetBody != nil {
+			if request.Body, err = request.GetBody(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// doOnce sends request to the hue bridge once and returns the raw
+// response along with the HTTP status code.
+func (c *Context) doOnce(
+	ctx context.Context, request *http.Request) (
+	response []byte, statusCode int, err error) {
+	var resp *http.Response
+	if resp, err = c.client.Do(request.WithContext(ctx)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	statusCode = resp.StatusCode
+	var respBuffer bytes.Buffer
+	// Read one extra byte so that we can tell if the response is too large.
+	var n int64
+	if n, err = respBuffer.ReadFrom(
+		io.LimitReader(resp.Body, c.maxResponseBytes+1)); err != nil {
+		return
+	}
+	if n > c.maxResponseBytes {
+		err = ErrResponseTooLarge
+		return
+	}
+	response = respBuffer.Bytes()
+	return
+}
+
+func toLightProperties(state *json_structs.LightProperties) *LightProperties {
+	color := MaybeColor{Valid: false, Color: White}
+	if len(state.XY) == 2 {
+		jsonColor := state.XY
+		color = NewMaybeColor(NewColor(jsonColor[0], jsonColor[1]))
+	}
+	var ct maybe.Uint16
+	if state.Ct != nil {
+		ct.Set(*state.Ct)
+	}
+	var hue maybe.Uint16
+	if state.Hue != nil {
+		hue.Set(*state.Hue)
+	}
+	var sat maybe.Uint8
+	if state.Sat != nil {
+		sat.Set(*state.Sat)
+	}
+	return &LightProperties{
+		C:      color,
+		Hue:    hue,
+		Sat:    sat,
+		Bri:    maybe.NewUint8(state.Bri),
+		On:     maybe.NewBool(state.On),
+		Ct:     ct,
+		Alert:  Alert(state.Alert),
+		Effect: Effect(state.Effect)}
+}
+
 func (c *Context) getLightUrl(id int) *url.URL {
 	return &url.URL{
 		Scheme: "http",
 		Host:   c.ipAddress,
-		Path:   fmt.Sprintf("/api/%s/lights/%d", c.userId, id),
+		Path:   fmt.Sprintf("%s/%s/lights/%d", c.basePath, c.userId, id),
 	}
 }
 
 func (c *Context) lightUrl(id int) *url.URL {
 	if id == 0 {
-		return c.allUrl
+		return c.groupUrl(0)
 	}
 	return &url.URL{
 		Scheme: "http",
 		Host:   c.ipAddress,
-		Path:   fmt.Sprintf("/api/%s/lights/%d/state", c.userId, id),
+		Path: fmt.Sprintf(
+			"%s/%s/lights/%d/state", c.basePath, c.userId, id),
+	}
+}
+
+func (c *Context) groupUrl(id int) *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   c.ipAddress,
+		Path: fmt.Sprintf(
+			"%s/%s/groups/%d/action", c.basePath, c.userId, id),
 	}
 }
 
@@ -325,13 +707,23 @@ func timeoutDialer(
 func toError(rawResponse []byte) error {
 	var response []json_structs.GeneralResponse
 	if err := json.Unmarshal(rawResponse, &response); err != nil {
-		return nil
+		return unexpectedResponseError(rawResponse)
 	}
 	if len(response) > 0 && response[0].Error != nil {
-		if response[0].Error.ErrorId == 3 {
-			return NoSuchResourceError
+		jsonErr := response[0].Error
+		return &BridgeError{
+			ErrorId:     jsonErr.ErrorId,
+			Address:     jsonErr.Address,
+			Description: jsonErr.Description,
 		}
-		return GeneralError
 	}
 	return nil
 }
+
+func unexpectedResponseError(rawResponse []byte) error {
+	snippet := rawResponse
+	if len(snippet) > kMaxSnippetBytes {
+		snippet = snippet[:kMaxSnippetBytes]
+	}
+	return fmt.Errorf("%w %q", ErrUnexpectedResponse, snippet)
+}
@@ -6,8 +6,17 @@
 package gohue_test
 
 import (
+	"errors"
 	"github.com/keep94/gohue"
+	"github.com/keep94/maybe"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestColorBlend(t *testing.T) {
@@ -57,6 +66,387 @@ func TestMaybeColor(t *testing.T) {
 	verifyString(t, "Nothing", m.String())
 }
 
+func TestConcurrentSetGet(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				w.Write(([]byte)(`[{"success":{}}]`))
+				return
+			}
+			w.Write(([]byte)(
+				`{"state":{"on":true,"bri":100,"xy":[0.4,0.6]}}`))
+		}, nil)
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	properties.Bri.Set(100)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(lightId int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := context.Set(lightId, &properties); err != nil {
+					t.Errorf("Got error %v", err)
+					return
+				}
+				props, _, err := context.Get(lightId + 1)
+				if err != nil {
+					t.Errorf("Got error %v", err)
+					return
+				}
+				if props.Bri != maybe.NewUint8(100) {
+					t.Errorf("Expected 100, got %v", props.Bri)
+				}
+			}
+		}(i % 3)
+	}
+	wg.Wait()
+}
+
+func TestParameterNotModifiable(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"error":{"type":201,` +
+				`"address":"/lights/1/state/bri",` +
+				`"description":"parameter, bri, is not modifiable. ` +
+				`Device is set to off."}}]`))
+		}, nil)
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.Bri.Set(100)
+	_, err := context.Set(1, &properties)
+	if !errors.Is(err, gohue.ErrParameterNotModifiable) {
+		t.Errorf("Expected ErrParameterNotModifiable, got %v", err)
+	}
+}
+
+func TestDefaultTransitionTime(t *testing.T) {
+	var body []byte
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{DefaultTransitionTime: maybe.NewUint16(4)})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"on":true,"transitiontime":4}`, string(body))
+	properties.TransitionTime.Set(0)
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"on":true,"transitiontime":0}`, string(body))
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{MaxResponseBytes: 10})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err != gohue.ErrResponseTooLarge {
+		t.Errorf("Expected ErrResponseTooLarge, got %v", err)
+	}
+	server, context = newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{MaxResponseBytes: 16})
+	defer server.Close()
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{RequestTimeout: 20 * time.Millisecond})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err == nil {
+		t.Error("Expected a timeout error.")
+	}
+}
+
+func TestUnexpectedResponse(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)("<html><body>Router login</body></html>"))
+		}, nil)
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	_, err := context.Set(1, &properties)
+	if !errors.Is(err, gohue.ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+	_, _, err = context.Get(1)
+	if !errors.Is(err, gohue.ErrUnexpectedResponse) {
+		t.Errorf("Expected ErrUnexpectedResponse, got %v", err)
+	}
+	if out := err.Error(); !strings.Contains(out, "Router login") {
+		t.Errorf("Expected snippet of response, got %s", out)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	var paths []string
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			if r.Method == "PUT" {
+				w.Write(([]byte)(`[{"success":{}}]`))
+				return
+			}
+			w.Write(([]byte)(`{"state":{"on":true,"bri":100}}`))
+		},
+		&gohue.Options{BasePath: "/deconz/api/"})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(0, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if _, err := context.Set(2, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if _, _, err := context.Get(3); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if _, err := context.SetGroup(5, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expected := []string{
+		"/deconz/api/user/groups/0/action",
+		"/deconz/api/user/lights/2/state",
+		"/deconz/api/user/lights/3",
+		"/deconz/api/user/groups/5/action"}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.Write(([]byte)(`{"state":{"on":true,"bri":100}}`))
+	}
+	server, context := newServerForTesting(handler, nil)
+	defer server.Close()
+	if _, _, err := context.Get(1); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, "gohue (github.com/keep94/gohue)", userAgent)
+	server, context = newServerForTesting(
+		handler, &gohue.Options{UserAgent: "myapp/2.0"})
+	defer server.Close()
+	if _, _, err := context.Get(1); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, "myapp/2.0", userAgent)
+}
+
+func TestColorTemperature(t *testing.T) {
+	var body []byte
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				body, _ = ioutil.ReadAll(r.Body)
+				w.Write(([]byte)(`[{"success":{}}]`))
+				return
+			}
+			if r.URL.Path == "/api/user/lights/1" {
+				w.Write(([]byte)(`{"state":{"on":true,"bri":100,"ct":370}}`))
+				return
+			}
+			w.Write(([]byte)(`{"state":{"on":true,"bri":100}}`))
+		}, nil)
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.Ct.Set(gohue.MiredFromKelvin(2700))
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"ct":370}`, string(body))
+	props, _, err := context.Get(1)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if props.Ct != maybe.NewUint16(370) {
+		t.Errorf("Expected 370, got %v", props.Ct)
+	}
+	props, _, err = context.Get(2)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if props.Ct.Valid {
+		t.Errorf("Expected nothing, got %v", props.Ct)
+	}
+}
+
+func TestAlertAndEffect(t *testing.T) {
+	var body []byte
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				body, _ = ioutil.ReadAll(r.Body)
+				w.Write(([]byte)(`[{"success":{}}]`))
+				return
+			}
+			w.Write(([]byte)(`{"state":{"on":true,"bri":100,` +
+				`"alert":"none","effect":"colorloop"}}`))
+		}, nil)
+	defer server.Close()
+	properties := gohue.LightProperties{Alert: gohue.AlertLSelect}
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"alert":"lselect"}`, string(body))
+	props, _, err := context.Get(1)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if props.Alert != gohue.AlertNone || props.Effect != gohue.EffectColorLoop {
+		t.Errorf("Expected none and colorloop, got %v", props)
+	}
+}
+
+func TestHueSat(t *testing.T) {
+	var body []byte
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				body, _ = ioutil.ReadAll(r.Body)
+				w.Write(([]byte)(`[{"success":{}}]`))
+				return
+			}
+			if r.URL.Path == "/api/user/lights/1" {
+				w.Write(([]byte)(
+					`{"state":{"on":true,"bri":100,"hue":0,"sat":254}}`))
+				return
+			}
+			w.Write(([]byte)(`{"state":{"on":true,"bri":100,"xy":[0.3,0.3]}}`))
+		}, nil)
+	defer server.Close()
+	properties := gohue.LightProperties{
+		Hue: maybe.NewUint16(21845), Sat: maybe.NewUint8(200)}
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"hue":21845,"sat":200}`, string(body))
+	// XY wins over hue and sat.
+	properties.C.Set(gohue.NewColor(0.3, 0.3))
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	verifyString(t, `{"xy":[0.3,0.3]}`, string(body))
+	props, _, err := context.Get(1)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if props.Hue != maybe.NewUint16(0) || props.Sat != maybe.NewUint8(254) {
+		t.Errorf("Expected hue 0 and sat 254, got %v", props)
+	}
+	if props.C.Valid {
+		t.Errorf("Expected no color, got %v", props.C)
+	}
+	props, _, err = context.Get(2)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if props.Hue.Valid || props.Sat.Valid {
+		t.Errorf("Expected no hue or sat, got %v", props)
+	}
+}
+
+func TestMiredFromKelvin(t *testing.T) {
+	if out := gohue.MiredFromKelvin(6500); out != 154 {
+		t.Errorf("Expected 154, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(2000); out != 500 {
+		t.Errorf("Expected 500, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(1000); out != 500 {
+		t.Errorf("Expected 500, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(10); out != 500 {
+		t.Errorf("Expected 500, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(1); out != 500 {
+		t.Errorf("Expected 500, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(10000); out != 153 {
+		t.Errorf("Expected 153, got %d", out)
+	}
+	if out := gohue.MiredFromKelvin(0); out != 0 {
+		t.Errorf("Expected 0, got %d", out)
+	}
+}
+
+func TestBridgeError(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/user/lights/1/state" {
+				w.Write(([]byte)(`[{"error":{"type":7,` +
+					`"address":"/lights/1/state/bri",` +
+					`"description":"invalid value, 300, for parameter, bri"}}]`))
+				return
+			}
+			w.Write(([]byte)(`[{"error":{"type":3,"address":"/lights/2",` +
+				`"description":"resource, /lights/2, not available"}}]`))
+		}, nil)
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.Bri.Set(100)
+	_, err := context.Set(1, &properties)
+	bridgeErr, ok := err.(*gohue.BridgeError)
+	if !ok {
+		t.Fatalf("Expected BridgeError, got %v", err)
+	}
+	expected := gohue.BridgeError{
+		ErrorId:     7,
+		Address:     "/lights/1/state/bri",
+		Description: "invalid value, 300, for parameter, bri"}
+	if *bridgeErr != expected {
+		t.Errorf("Expected %v, got %v", expected, *bridgeErr)
+	}
+	if !errors.Is(err, gohue.GeneralError) {
+		t.Error("Expected BridgeError to be a GeneralError.")
+	}
+	if errors.Is(err, gohue.NoSuchResourceError) {
+		t.Error("Expected BridgeError not to be a NoSuchResourceError.")
+	}
+	_, _, err = context.Get(2)
+	if !errors.Is(err, gohue.NoSuchResourceError) {
+		t.Errorf("Expected NoSuchResourceError, got %v", err)
+	}
+	if _, ok := err.(*gohue.BridgeError); !ok {
+		t.Errorf("Expected BridgeError, got %v", err)
+	}
+}
+
+func newServerForTesting(
+	handler http.HandlerFunc,
+	options *gohue.Options) (*httptest.Server, *gohue.Context) {
+	server := httptest.NewServer(handler)
+	return server, gohue.NewContextWithOptions(
+		strings.TrimPrefix(server.URL, "http://"), "user", options)
+}
+
 func verifyString(t *testing.T, expected, actual string) {
 	if expected != actual {
 		t.Errorf("Expected %s, got %s", expected, actual)
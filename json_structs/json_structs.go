@@ -7,14 +7,39 @@
 // These should not be used directly.
 package json_structs
 
+type Lights map[string]LightState
+
 type LightState struct {
-	State *LightProperties
+	State        *LightProperties
+	Name         string
+	ModelId      string
+	Type         string
+	Capabilities *LightCapabilities
+}
+
+type LightCapabilities struct {
+	Control LightControl
+}
+
+type LightControl struct {
+	ColorGamutType string
+	Ct             *CtRange
+}
+
+type CtRange struct {
+	Min uint16
+	Max uint16
 }
 
 type LightProperties struct {
-	On  bool
-	Bri uint8
-	XY  []float64
+	On     bool
+	Bri    uint8
+	XY     []float64
+	Ct     *uint16
+	Hue    *uint16
+	Sat    *uint8
+	Alert  string
+	Effect string
 }
 
 type GeneralResponse struct {
@@ -26,3 +51,16 @@ type SingleError struct {
 	Address     string
 	Description string
 }
+
+type BridgeInfo struct {
+	Id                string
+	InternalIpAddress string
+}
+
+type Groups map[string]Group
+
+type Group struct {
+	Name   string
+	Type   string
+	Lights []string
+}
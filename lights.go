@@ -0,0 +1,84 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/keep94/gohue/json_structs"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// LightAttributes describes a light on the hue bridge.
+type LightAttributes struct {
+
+	// The ID of the light.
+	Id int
+
+	// The human readable name of the light.
+	Name string
+
+	// The model ID of the light such as "LCT001".
+	ModelId string
+
+	// The current properties of the light. nil if the hue bridge did not
+	// report the state of the light.
+	Properties *LightProperties
+}
+
+// Lights returns every light on the hue bridge keyed by light ID.
+// A light whose state is missing is still returned but with nil
+// Properties.
+func (c *Context) Lights() (map[int]LightAttributes, error) {
+	return c.lights(context.Background())
+}
+
+func (c *Context) lights(ctx context.Context) (
+	map[int]LightAttributes, error) {
+	request := &http.Request{
+		Method: "GET",
+		URL:    c.getAllLightsUrl(),
+	}
+	response, err := c.do(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var jsonLights json_structs.Lights
+	if err := json.Unmarshal(response, &jsonLights); err != nil {
+		if err := toError(response); err != nil {
+			return nil, err
+		}
+		return nil, unexpectedResponseError(response)
+	}
+	result := make(map[int]LightAttributes, len(jsonLights))
+	for idStr, jsonLight := range jsonLights {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		light := LightAttributes{
+			Id:      id,
+			Name:    jsonLight.Name,
+			ModelId: jsonLight.ModelId,
+		}
+		if jsonLight.State != nil {
+			light.Properties = toLightProperties(jsonLight.State)
+		}
+		result[id] = light
+	}
+	return result, nil
+}
+
+func (c *Context) getAllLightsUrl() *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   c.ipAddress,
+		Path:   fmt.Sprintf("%s/%s/lights", c.basePath, c.userId),
+	}
+}
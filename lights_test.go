@@ -0,0 +1,63 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"errors"
+	"github.com/keep94/gohue"
+	"github.com/keep94/maybe"
+	"net/http"
+	"testing"
+)
+
+func TestLights(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/user/lights" {
+				w.Write(([]byte)(`[{"error":{"type":3}}]`))
+				return
+			}
+			w.Write(([]byte)(`{"1":{"name":"Kitchen","modelid":"LCT001",` +
+				`"state":{"on":true,"bri":200,"xy":[0.4,0.5]}},` +
+				`"3":{"name":"Hall","modelid":"LWB004"},` +
+				`"bogus":{"name":"Bogus"}}`))
+		}, nil)
+	defer server.Close()
+	lights, err := context.Lights()
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if out := len(lights); out != 2 {
+		t.Fatalf("Expected 2 lights, got %d", out)
+	}
+	kitchen := lights[1]
+	if kitchen.Id != 1 || kitchen.Name != "Kitchen" ||
+		kitchen.ModelId != "LCT001" {
+		t.Errorf("Unexpected light %v", kitchen)
+	}
+	expected := gohue.LightProperties{
+		C:   gohue.NewMaybeColor(gohue.NewColor(0.4, 0.5)),
+		Bri: maybe.NewUint8(200),
+		On:  maybe.NewBool(true)}
+	if kitchen.Properties == nil || *kitchen.Properties != expected {
+		t.Errorf("Expected %v, got %v", expected, kitchen.Properties)
+	}
+	hall := lights[3]
+	if hall.Id != 3 || hall.Name != "Hall" || hall.Properties != nil {
+		t.Errorf("Unexpected light %v", hall)
+	}
+}
+
+func TestLightsError(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"error":{"type":1}}]`))
+		}, nil)
+	defer server.Close()
+	if _, err := context.Lights(); !errors.Is(err, gohue.GeneralError) {
+		t.Errorf("Expected GeneralError, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"sync"
+	"time"
+)
+
+// SetMultiple sets the properties of several lights at once. Rather than
+// setting lights one at a time, SetMultiple sends up to
+// Options.MaxConcurrentRequests requests at a time while sending no more
+// than Options.MaxRequestsPerSecond requests per second. The rate limit is
+// shared by all SetMultiple calls on this instance. The returned errors
+// line up with lightIds; a nil entry means that light was set
+// successfully.
+func (c *Context) SetMultiple(
+	lightIds []int, properties *LightProperties) []error {
+	result := make([]error, len(lightIds))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	workers := c.maxConcurrentRequests
+	if workers > len(lightIds) {
+		workers = len(lightIds)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				c.limiter.Wait()
+				_, result[idx] = c.Set(lightIds[idx], properties)
+			}
+		}()
+	}
+	for i := range lightIds {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return result
+}
+
+// rateLimiter spaces out events by a minimum interval.
+// rateLimiter instances are safe to use with multiple goroutines.
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+// Wait blocks until the next event may happen.
+func (r *rateLimiter) Wait() {
+	r.mu.Lock()
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.interval)
+	r.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
@@ -0,0 +1,75 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"errors"
+	"github.com/keep94/gohue"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetMultiple(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			if r.URL.Path == "/api/user/lights/3/state" {
+				w.Write(([]byte)(`[{"error":{"type":3}}]`))
+				return
+			}
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{MaxConcurrentRequests: 2, MaxRequestsPerSecond: 100})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	errs := context.SetMultiple([]int{1, 2, 3, 4, 5, 6}, &properties)
+	if out := len(errs); out != 6 {
+		t.Fatalf("Expected 6 errors, got %d", out)
+	}
+	for i, err := range errs {
+		if i == 2 {
+			if !errors.Is(err, gohue.NoSuchResourceError) {
+				t.Errorf("Expected NoSuchResourceError, got %v", err)
+			}
+		} else if err != nil {
+			t.Errorf("Expected no error for light %d, got %v", i+1, err)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", maxInFlight)
+	}
+}
+
+func TestSetMultipleRateLimit(t *testing.T) {
+	server, context := newServerForTesting(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(([]byte)(`[{"success":{}}]`))
+		},
+		&gohue.Options{MaxConcurrentRequests: 4, MaxRequestsPerSecond: 50})
+	defer server.Close()
+	var properties gohue.LightProperties
+	properties.On.Set(true)
+	start := time.Now()
+	context.SetMultiple([]int{1, 2, 3, 4, 5, 6}, &properties)
+	// 6 requests at 50 per second are spaced 20ms apart.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected at least 100ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryConnectionErrors(t *testing.T) {
+	transport := &roundTripperForTesting{
+		failures: 2, body: `[{"success":{}}]`}
+	context := newContextForTesting(transport, 3)
+	var properties LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if out := transport.calls; out != 3 {
+		t.Errorf("Expected 3 calls, got %d", out)
+	}
+	if out := transport.lastBody; out != `{"on":true}` {
+		t.Errorf("Expected body to be resent, got %s", out)
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	transport := &roundTripperForTesting{
+		failures:   2,
+		failStatus: 500,
+		body:       `{"state":{"on":true,"bri":100}}`}
+	context := newContextForTesting(transport, 3)
+	if _, _, err := context.Get(1); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if out := transport.calls; out != 3 {
+		t.Errorf("Expected 3 calls, got %d", out)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	transport := &roundTripperForTesting{
+		failures: 5, body: `[{"success":{}}]`}
+	context := newContextForTesting(transport, 2)
+	var properties LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err == nil {
+		t.Error("Expected an error.")
+	}
+	if out := transport.calls; out != 3 {
+		t.Errorf("Expected 3 calls, got %d", out)
+	}
+}
+
+func TestRetryGivesUpOnServerErrors(t *testing.T) {
+	transport := &roundTripperForTesting{
+		failures:   5,
+		failStatus: 503,
+		body:       `[{"success":{}}]`}
+	context := newContextForTesting(transport, 2)
+	var properties LightProperties
+	properties.On.Set(true)
+	response, err := context.Set(1, &properties)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected HTTP 503 error, got %v", err)
+	}
+	if out := string(response); out != `[{"success":{}}]` {
+		t.Errorf("Expected raw response, got %s", out)
+	}
+	if errors.Is(err, ErrUnexpectedResponse) {
+		t.Error("Expected error not to be ErrUnexpectedResponse.")
+	}
+	if out := transport.calls; out != 3 {
+		t.Errorf("Expected 3 calls, got %d", out)
+	}
+}
+
+func TestNoRetryOnBridgeError(t *testing.T) {
+	transport := &roundTripperForTesting{body: `[{"error":{"type":3}}]`}
+	context := newContextForTesting(transport, 3)
+	var properties LightProperties
+	properties.On.Set(true)
+	_, err := context.Set(1, &properties)
+	if !errors.Is(err, NoSuchResourceError) {
+		t.Errorf("Expected NoSuchResourceError, got %v", err)
+	}
+	if out := transport.calls; out != 1 {
+		t.Errorf("Expected 1 call, got %d", out)
+	}
+}
+
+func TestRetryHonorsTimeout(t *testing.T) {
+	transport := &roundTripperForTesting{
+		failures: 5, body: `[{"success":{}}]`}
+	context := NewContextWithOptions("bridge", "user", &Options{
+		Timeout:      50 * time.Millisecond,
+		MaxRetries:   5,
+		RetryBackoff: 20 * time.Millisecond})
+	context.client.Transport = transport
+	var properties LightProperties
+	properties.On.Set(true)
+	if _, err := context.Set(1, &properties); err == nil {
+		t.Error("Expected an error.")
+	}
+	// Waits of 20ms and 40ms would exceed the 50ms timeout.
+	if out := transport.calls; out != 2 {
+		t.Errorf("Expected 2 calls, got %d", out)
+	}
+}
+
+func newContextForTesting(
+	transport http.RoundTripper, maxRetries int) *Context {
+	result := NewContextWithOptions("bridge", "user", &Options{
+		MaxRetries: maxRetries, RetryBackoff: time.Millisecond})
+	result.client.Transport = transport
+	return result
+}
+
+// roundTripperForTesting fails a certain number of times and then
+// succeeds. It fails with a connection error unless failStatus is set.
+type roundTripperForTesting struct {
+	failures   int
+	failStatus int
+	body       string
+	calls      int
+	lastBody   string
+}
+
+func (r *roundTripperForTesting) RoundTrip(
+	request *http.Request) (*http.Response, error) {
+	r.calls++
+	if request.Body != nil {
+		body, _ := ioutil.ReadAll(request.Body)
+		r.lastBody = string(body)
+	}
+	status := 200
+	if r.calls <= r.failures {
+		if r.failStatus == 0 {
+			return nil, errors.New("connection refused")
+		}
+		status = r.failStatus
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(r.body)),
+		Request:    request,
+	}, nil
+}
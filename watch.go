@@ -0,0 +1,87 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue
+
+import (
+	"context"
+	"time"
+)
+
+// LightEvent reports that the state of a light changed.
+type LightEvent struct {
+
+	// The ID of the light that changed.
+	LightId int
+
+	// The new properties of the light.
+	Properties *LightProperties
+}
+
+// Watch reports changes to the state of the lights on the hue bridge.
+// Watch works by polling the state of all the lights every
+// Options.WatchInterval and emitting a LightEvent for each light whose
+// state differs from the previous poll. The first poll happens before
+// Watch returns and establishes the baseline; it emits no events. If the
+// first poll fails, Watch returns the error. Errors from subsequent polls
+// are ignored, and polling continues. Cancelling ctx stops polling and
+// closes the returned channel.
+func (c *Context) Watch(ctx context.Context) (<-chan LightEvent, error) {
+	current, err := c.getAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(chan LightEvent)
+	go c.watch(ctx, current, result)
+	return result, nil
+}
+
+func (c *Context) watch(
+	ctx context.Context,
+	current map[int]*LightProperties,
+	events chan<- LightEvent) {
+	defer close(events)
+	ticker := time.NewTicker(c.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		latest, err := c.getAll(ctx)
+		if err != nil {
+			continue
+		}
+		for id, properties := range latest {
+			if old, ok := current[id]; ok && *old == *properties {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case events <- LightEvent{LightId: id, Properties: properties}:
+			}
+		}
+		current = latest
+	}
+}
+
+// getAll returns the properties of every light keyed by light ID.
+// Lights that report no state are skipped.
+func (c *Context) getAll(ctx context.Context) (
+	map[int]*LightProperties, error) {
+	lights, err := c.lights(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]*LightProperties, len(lights))
+	for id, light := range lights {
+		if light.Properties != nil {
+			result[id] = light.Properties
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Travis Keep. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or
+// at http://opensource.org/licenses/BSD-3-Clause.
+
+package gohue_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/keep94/gohue"
+	"github.com/keep94/maybe"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			bri := 100
+			if atomic.AddInt32(&polls, 1) > 1 {
+				bri = 150
+			}
+			fmt.Fprintf(w, `{"1":{"state":{"on":true,"bri":%d}},`+
+				`"2":{"state":{"on":false,"bri":10}}}`, bri)
+		}))
+	defer server.Close()
+	hueContext := gohue.NewContextWithOptions(
+		strings.TrimPrefix(server.URL, "http://"),
+		"user",
+		&gohue.Options{WatchInterval: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := hueContext.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	event := <-events
+	if out := event.LightId; out != 1 {
+		t.Errorf("Expected light 1, got %d", out)
+	}
+	if out := event.Properties.Bri; out != maybe.NewUint8(150) {
+		t.Errorf("Expected 150, got %v", out)
+	}
+	cancel()
+	for range events {
+	}
+}